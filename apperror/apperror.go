@@ -0,0 +1,181 @@
+// Package apperror defines the errors the API answers with. Every error has
+// a stable code clients can branch on, an HTTP status and a message that is
+// safe to show to users. Handlers return these errors and Handler writes
+// them; the internal cause of an error is logged but never sent.
+package apperror
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Error is an API error.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	// Details is optional extra data for the client, such as the invalid
+	// fields of a request.
+	Details interface{}
+	cause   error
+}
+
+// New returns an error with the given status, code and user-safe message.
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.cause.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+// Unwrap returns the internal cause of the error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is an Error with the same code, so errors.Is
+// matches copies made by Wrap, WithDetails and WithMessage.
+func (e *Error) Is(target error) bool {
+	other, ok := target.(*Error)
+	return ok && other.Code == e.Code
+}
+
+// Wrap returns a copy of the error with an internal cause, which is logged
+// and never shown to the client.
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+// WithDetails returns a copy of the error carrying details for the client.
+func (e *Error) WithDetails(details interface{}) *Error {
+	detailed := *e
+	detailed.Details = details
+	return &detailed
+}
+
+// WithMessage returns a copy of the error with another message.
+func (e *Error) WithMessage(message string) *Error {
+	changed := *e
+	changed.Message = message
+	return &changed
+}
+
+// WithStatus returns a copy of the error with another HTTP status.
+func (e *Error) WithStatus(status int) *Error {
+	changed := *e
+	changed.Status = status
+	return &changed
+}
+
+// Internal is the error for unexpected failures such as database errors.
+// The client only sees a generic message.
+func Internal(err error) *Error {
+	return ErrInternal.Wrap(err)
+}
+
+// BadRequest is the error for invalid input with a message for the client.
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// Invalid is the error for input rejected by our own checks, whose error
+// messages are meant for the client. Errors that already are an Error keep
+// their code.
+func Invalid(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return BadRequest(upperFirst(err.Error()))
+}
+
+// InvalidBody is the error for request bodies c.Bind cannot decode.
+func InvalidBody(err error) *Error {
+	invalid := ErrInvalidBody.Wrap(err)
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		invalid.Status = httpErr.Code
+		if message, ok := httpErr.Message.(string); ok {
+			invalid.Message = message
+		}
+	}
+	return invalid
+}
+
+// From turns any error into an Error. Echo's own errors, such as unknown
+// routes, keep their status; anything else is an internal error.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.Code >= http.StatusInternalServerError {
+			return Internal(err)
+		}
+		converted := New(httpErr.Code, statusCode(httpErr.Code), http.StatusText(httpErr.Code)).Wrap(err)
+		if message, ok := httpErr.Message.(string); ok {
+			converted.Message = message
+		}
+		return converted
+	}
+	return Internal(err)
+}
+
+// statusCode derives a code from an HTTP status, e.g. METHOD_NOT_ALLOWED.
+func statusCode(status int) Code {
+	text := strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(http.StatusText(status))
+	if text == "" {
+		return CodeBadRequest
+	}
+	return Code(strings.ToUpper(text))
+}
+
+func upperFirst(message string) string {
+	if message == "" {
+		return message
+	}
+	return strings.ToUpper(message[:1]) + message[1:]
+}
+
+// Handler is the Echo HTTPErrorHandler. It answers every error returned by
+// a handler or middleware with a models.ErrorResponse carrying the request
+// ID, and logs the cause of server errors with that ID.
+func Handler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	appErr := From(err)
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if appErr.Status >= http.StatusInternalServerError {
+		log.Printf("request %s: %s %s: %v", requestID, c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(appErr.Status)
+	} else {
+		err = c.JSON(appErr.Status, models.ErrorResponse{
+			Error:     appErr.Message,
+			Code:      string(appErr.Code),
+			Details:   appErr.Details,
+			RequestID: requestID,
+		})
+	}
+	if err != nil {
+		log.Printf("request %s: writing error response: %v", requestID, err)
+	}
+}
@@ -0,0 +1,138 @@
+package apperror
+
+import "net/http"
+
+// Code is a stable, machine-readable error code. Codes never change once
+// published; messages may.
+type Code string
+
+const (
+	CodeBadRequest           Code = "BAD_REQUEST"
+	CodeInvalidBody          Code = "INVALID_REQUEST_BODY"
+	CodeBodyTooLarge         Code = "REQUEST_BODY_TOO_LARGE"
+	CodeValidationFailed     Code = "VALIDATION_FAILED"
+	CodeInvalidCursor        Code = "INVALID_CURSOR"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeFieldNotAllowed      Code = "FIELD_NOT_ALLOWED"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeTooManyRequests      Code = "TOO_MANY_REQUESTS"
+	CodeVersionConflict      Code = "VERSION_CONFLICT"
+	CodePreconditionRequired Code = "PRECONDITION_REQUIRED"
+	CodeInternal             Code = "INTERNAL_SERVER_ERROR"
+	CodeInvalidCredentials   Code = "AUTH_INVALID_CREDENTIALS"
+	CodeInvalidToken         Code = "AUTH_INVALID_TOKEN"
+	CodeTokenRevoked         Code = "AUTH_TOKEN_REVOKED"
+	CodeInvalidRefreshToken  Code = "AUTH_INVALID_REFRESH_TOKEN"
+	CodeInvalidAPIKey        Code = "AUTH_INVALID_API_KEY"
+	CodeInvalidResetToken    Code = "AUTH_INVALID_RESET_TOKEN"
+	CodeWrongPassword        Code = "AUTH_WRONG_PASSWORD"
+	CodeAccountInactive      Code = "AUTH_ACCOUNT_INACTIVE"
+	CodeLoginThrottled       Code = "AUTH_LOGIN_THROTTLED"
+	CodeInvalidChallenge     Code = "TWO_FACTOR_INVALID_CHALLENGE"
+	CodeInvalidTwoFactorCode Code = "TWO_FACTOR_INVALID_CODE"
+	CodeTwoFactorEnabled     Code = "TWO_FACTOR_ALREADY_ENABLED"
+	CodeTwoFactorNotEnrolled Code = "TWO_FACTOR_NOT_ENROLLED"
+	CodeTwoFactorRequired    Code = "TWO_FACTOR_REQUIRED"
+	CodeAlreadyClockedIn     Code = "ATTENDANCE_ALREADY_CLOCKED_IN"
+	CodeNotClockedIn         Code = "ATTENDANCE_NOT_CLOCKED_IN"
+	CodeEmployeeNotFound     Code = "EMPLOYEE_NOT_FOUND"
+	CodeUsernameTaken        Code = "EMPLOYEE_USERNAME_TAKEN"
+	CodeEmailTaken           Code = "EMPLOYEE_EMAIL_TAKEN"
+	CodeAlreadyTerminated    Code = "EMPLOYEE_ALREADY_TERMINATED"
+	CodeAlreadyActive        Code = "EMPLOYEE_ALREADY_ACTIVE"
+	CodeImportFile           Code = "EMPLOYEE_IMPORT_INVALID_FILE"
+	CodeImportRows           Code = "EMPLOYEE_IMPORT_INVALID_ROWS"
+	CodeLeaveNotFound        Code = "LEAVE_NOT_FOUND"
+	CodeLeaveReviewed        Code = "LEAVE_ALREADY_REVIEWED"
+	CodeLeaveOwnReview       Code = "LEAVE_OWN_REQUEST"
+	CodeRoleNotFound         Code = "ROLE_NOT_FOUND"
+	CodeRoleNameTaken        Code = "ROLE_NAME_TAKEN"
+	CodeRoleNameReserved     Code = "ROLE_NAME_RESERVED"
+	CodeRoleBuiltIn          Code = "ROLE_BUILT_IN"
+	CodeUnknownRole          Code = "ROLE_UNKNOWN"
+	CodeUnknownPermission    Code = "PERMISSION_UNKNOWN"
+	CodeDepartmentNotFound   Code = "DEPARTMENT_NOT_FOUND"
+	CodeDepartmentNotEmpty   Code = "DEPARTMENT_NOT_EMPTY"
+	CodeTeamNotFound         Code = "TEAM_NOT_FOUND"
+	CodeTeamNotEmpty         Code = "TEAM_NOT_EMPTY"
+	CodePayPeriodNotFound    Code = "PAY_PERIOD_NOT_FOUND"
+	CodePayPeriodClosed      Code = "PAY_PERIOD_CLOSED"
+	CodeAPIKeyNotFound       Code = "API_KEY_NOT_FOUND"
+	CodeUnknownScope         Code = "API_KEY_UNKNOWN_SCOPE"
+	CodeScopeNotHeld         Code = "API_KEY_SCOPE_NOT_HELD"
+	CodeWebhookNotFound      Code = "WEBHOOK_NOT_FOUND"
+	CodeDeliveryNotFound     Code = "WEBHOOK_DELIVERY_NOT_FOUND"
+	CodeNotificationNotFound Code = "NOTIFICATION_NOT_FOUND"
+	CodeNotificationNotRetry Code = "NOTIFICATION_NOT_RETRYABLE"
+)
+
+// Errors shared by several handlers. They are never modified; Wrap,
+// WithDetails and WithMessage return copies.
+var (
+	ErrInternal             = New(http.StatusInternalServerError, CodeInternal, "Something went wrong, please try again later")
+	ErrInvalidBody          = New(http.StatusBadRequest, CodeInvalidBody, "The request body is malformed")
+	ErrBodyTooLarge         = New(http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "The request body is too large")
+	ErrValidationFailed     = New(http.StatusUnprocessableEntity, CodeValidationFailed, "Validation failed")
+	ErrInvalidCursor        = New(http.StatusBadRequest, CodeInvalidCursor, "Invalid cursor")
+	ErrUnauthorized         = New(http.StatusUnauthorized, CodeUnauthorized, "Authentication required")
+	ErrForbidden            = New(http.StatusForbidden, CodeForbidden, "You do not have permission to access this resource")
+	ErrFieldNotAllowed      = New(http.StatusForbidden, CodeFieldNotAllowed, "You may not change some of these fields")
+	ErrVersionConflict      = New(http.StatusPreconditionFailed, CodeVersionConflict, "The resource has changed, fetch it again and retry")
+	ErrPreconditionRequired = New(http.StatusPreconditionRequired, CodePreconditionRequired, "Send the ETag of the resource in If-Match")
+
+	ErrInvalidCredentials  = New(http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+	ErrInvalidToken        = New(http.StatusUnauthorized, CodeInvalidToken, "Invalid or expired token")
+	ErrTokenRevoked        = New(http.StatusUnauthorized, CodeTokenRevoked, "Token has been revoked")
+	ErrInvalidRefreshToken = New(http.StatusUnauthorized, CodeInvalidRefreshToken, "Invalid or expired refresh token")
+	ErrInvalidAPIKey       = New(http.StatusUnauthorized, CodeInvalidAPIKey, "Invalid API key")
+	ErrInvalidResetToken   = New(http.StatusBadRequest, CodeInvalidResetToken, "Invalid or expired reset token")
+	ErrWrongPassword       = New(http.StatusBadRequest, CodeWrongPassword, "Current password is incorrect")
+	ErrAccountInactive     = New(http.StatusForbidden, CodeAccountInactive, "Account is not active")
+	ErrLoginThrottled      = New(http.StatusTooManyRequests, CodeLoginThrottled, "Too many failed login attempts, try again later")
+
+	ErrInvalidChallenge     = New(http.StatusUnauthorized, CodeInvalidChallenge, "Invalid or expired login challenge")
+	ErrInvalidTwoFactorCode = New(http.StatusBadRequest, CodeInvalidTwoFactorCode, "Invalid two-factor code")
+	ErrTwoFactorEnabled     = New(http.StatusConflict, CodeTwoFactorEnabled, "Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = New(http.StatusBadRequest, CodeTwoFactorNotEnrolled, "Two-factor enrollment has not been started")
+	ErrTwoFactorRequired    = New(http.StatusForbidden, CodeTwoFactorRequired, "Your role requires two-factor authentication")
+
+	ErrAlreadyClockedIn = New(http.StatusConflict, CodeAlreadyClockedIn, "You are already clocked in")
+	ErrNotClockedIn     = New(http.StatusConflict, CodeNotClockedIn, "You are not clocked in")
+
+	ErrEmployeeNotFound  = New(http.StatusNotFound, CodeEmployeeNotFound, "Employee not found")
+	ErrUsernameTaken     = New(http.StatusBadRequest, CodeUsernameTaken, "Username already exists")
+	ErrEmailTaken        = New(http.StatusBadRequest, CodeEmailTaken, "Email already exists")
+	ErrAlreadyTerminated = New(http.StatusBadRequest, CodeAlreadyTerminated, "Employee is already terminated")
+	ErrAlreadyActive     = New(http.StatusBadRequest, CodeAlreadyActive, "Employee is already active")
+	ErrImportFile        = New(http.StatusBadRequest, CodeImportFile, "Invalid import file")
+	ErrImportRows        = New(http.StatusBadRequest, CodeImportRows, "The file has invalid rows, nothing was imported")
+
+	ErrLeaveNotFound  = New(http.StatusNotFound, CodeLeaveNotFound, "Leave request not found")
+	ErrLeaveReviewed  = New(http.StatusBadRequest, CodeLeaveReviewed, "Leave request has already been reviewed")
+	ErrLeaveOwnReview = New(http.StatusForbidden, CodeLeaveOwnReview, "You cannot review your own leave request")
+
+	ErrRoleNotFound      = New(http.StatusNotFound, CodeRoleNotFound, "Role not found")
+	ErrRoleNameTaken     = New(http.StatusBadRequest, CodeRoleNameTaken, "Role name already exists")
+	ErrRoleNameReserved  = New(http.StatusBadRequest, CodeRoleNameReserved, "Role name is reserved")
+	ErrRoleBuiltIn       = New(http.StatusBadRequest, CodeRoleBuiltIn, "Built-in roles cannot be changed")
+	ErrUnknownRole       = New(http.StatusBadRequest, CodeUnknownRole, "Unknown role")
+	ErrUnknownPermission = New(http.StatusBadRequest, CodeUnknownPermission, "Unknown permission")
+
+	ErrDepartmentNotFound = New(http.StatusNotFound, CodeDepartmentNotFound, "Department not found")
+	ErrDepartmentNotEmpty = New(http.StatusBadRequest, CodeDepartmentNotEmpty, "Department is not empty")
+	ErrTeamNotFound       = New(http.StatusNotFound, CodeTeamNotFound, "Team not found")
+	ErrTeamNotEmpty       = New(http.StatusBadRequest, CodeTeamNotEmpty, "Team is not empty")
+
+	ErrPayPeriodNotFound = New(http.StatusNotFound, CodePayPeriodNotFound, "Pay period not found")
+	ErrPayPeriodClosed   = New(http.StatusBadRequest, CodePayPeriodClosed, "Pay period is already closed")
+
+	ErrAPIKeyNotFound = New(http.StatusNotFound, CodeAPIKeyNotFound, "API key not found")
+	ErrUnknownScope   = New(http.StatusBadRequest, CodeUnknownScope, "Unknown scope")
+	ErrScopeNotHeld   = New(http.StatusForbidden, CodeScopeNotHeld, "You cannot grant a scope you do not hold")
+
+	ErrWebhookNotFound      = New(http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+	ErrDeliveryNotFound     = New(http.StatusNotFound, CodeDeliveryNotFound, "Delivery not found")
+	ErrNotificationNotFound = New(http.StatusNotFound, CodeNotificationNotFound, "Notification not found")
+	ErrNotificationNotRetry = New(http.StatusBadRequest, CodeNotificationNotRetry, "Only failed notifications can be retried")
+)
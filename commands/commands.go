@@ -0,0 +1,48 @@
+// Package commands implements the command line subcommands of the
+// attendance binary, e.g. `go run main.go payroll-export -period 1`.
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var registry = map[string]command{}
+
+func register(name, usage string, run func(args []string) error) {
+	registry[name] = command{usage: usage, run: run}
+}
+
+// Run executes the subcommand named by args[0] and exits the process.
+func Run(args []string) {
+	cmd, ok := registry[args[0]]
+	if !ok {
+		printUsage()
+		os.Exit(2)
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func printUsage() {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: attendance [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nWithout a command the HTTP server is started. Commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, registry[name].usage)
+	}
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"attendance/utils"
+)
+
+func init() {
+	register("import-employees", "create or update employees from a CSV file", importEmployees)
+}
+
+func importEmployees(args []string) error {
+	flags := flag.NewFlagSet("import-employees", flag.ContinueOnError)
+	path := flags.String("file", "", "CSV file to import, - for stdin")
+	dryRun := flags.Bool("dry-run", false, "only validate the file")
+	template := flags.Bool("template", false, "print the CSV template and exit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *template {
+		return utils.WriteEmployeeImportTemplate(os.Stdout)
+	}
+	if *path == "" {
+		return errors.New("-file is required")
+	}
+
+	var r io.Reader = os.Stdin
+	if *path != "-" {
+		file, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	db, err := utils.Connect()
+	if err != nil {
+		return err
+	}
+
+	report, err := utils.ImportEmployees(db, r, *dryRun)
+	for _, row := range report.Rows {
+		status := row.Action
+		if len(row.Errors) > 0 {
+			status = "invalid: " + strings.Join(row.Errors, "; ")
+		}
+		fmt.Printf("row %d\t%s\t%s\n", row.Row, row.Username, status)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.DryRun {
+		fmt.Printf("dry run: %d to create, %d to update\n", report.Created, report.Updated)
+	} else {
+		fmt.Printf("%d created, %d updated\n", report.Created, report.Updated)
+	}
+	return nil
+}
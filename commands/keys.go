@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"attendance/utils"
+)
+
+func init() {
+	register("keys", "manage JWT signing keys: list, generate, activate, rotate, retire", keys)
+}
+
+func keys(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: keys list|generate|activate|rotate|retire [flags]")
+	}
+
+	flags := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	dir := flags.String("dir", utils.KeysDir(), "key directory")
+	alg := flags.String("alg", utils.AlgEdDSA, "algorithm of new keys: EdDSA or RS256")
+	kid := flags.String("kid", "", "key ID")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		ring, err := utils.LoadKeyRing(*dir)
+		if err != nil {
+			return err
+		}
+		for _, key := range ring.Sorted() {
+			marker := " "
+			if key == ring.Active {
+				marker = "*"
+			}
+			fmt.Printf("%s %-30s %-6s %s\n", marker, key.ID, key.Alg, key.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+
+	case "generate", "rotate":
+		key, err := utils.GenerateKey(*dir, *alg)
+		if err != nil {
+			return err
+		}
+		if args[0] == "rotate" {
+			if err := utils.ActivateKey(*dir, key.ID); err != nil {
+				return err
+			}
+			fmt.Println("generated and activated", key.ID)
+			return nil
+		}
+		fmt.Println("generated", key.ID)
+		return nil
+
+	case "activate", "retire":
+		if *kid == "" {
+			return errors.New("-kid is required")
+		}
+		if args[0] == "activate" {
+			if err := utils.ActivateKey(*dir, *kid); err != nil {
+				return err
+			}
+			fmt.Println("activated", *kid)
+			return nil
+		}
+		if err := utils.RetireKey(*dir, *kid); err != nil {
+			return err
+		}
+		fmt.Println("retired", *kid)
+		return nil
+	}
+	return fmt.Errorf("unknown keys subcommand %q", args[0])
+}
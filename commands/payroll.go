@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"attendance/models"
+	"attendance/utils"
+)
+
+func init() {
+	register("payroll-export", "export payroll for a pay period (csv, json or fixed)", payrollExport)
+}
+
+func payrollExport(args []string) error {
+	flags := flag.NewFlagSet("payroll-export", flag.ContinueOnError)
+	periodID := flags.Uint("period", 0, "pay period ID")
+	format := flags.String("format", "csv", "output format: csv, json or fixed")
+	layout := flags.String("layout", "default", "named column layout")
+	columns := flags.String("columns", "", "comma separated column list, overrides -layout")
+	output := flags.String("out", "", "output file (defaults to stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *periodID == 0 {
+		return errors.New("-period is required")
+	}
+
+	selected, err := utils.ResolvePayrollColumns(*layout, *columns)
+	if err != nil {
+		return err
+	}
+
+	db, err := utils.Connect()
+	if err != nil {
+		return err
+	}
+
+	var period models.PayPeriod
+	if err := db.First(&period, *periodID).Error; err != nil {
+		return err
+	}
+	rows, err := utils.PayrollRows(db, period)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return utils.WritePayroll(os.Stdout, *format, selected, rows)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := utils.WritePayroll(file, *format, selected, rows); err != nil {
+		file.Close()
+		return err
+	}
+	// a failed close can mean the export never reached the disk
+	return file.Close()
+}
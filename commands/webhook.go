@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"attendance/utils"
+)
+
+func init() {
+	register("webhook-receiver", "run a local endpoint that verifies and prints webhook deliveries", webhookReceiver)
+}
+
+func webhookReceiver(args []string) error {
+	flags := flag.NewFlagSet("webhook-receiver", flag.ContinueOnError)
+	addr := flags.String("addr", ":9090", "listen address")
+	secret := flags.String("secret", "", "webhook signing secret")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *secret == "" {
+		return errors.New("-secret is required")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		if err != nil || !utils.VerifyWebhookSignature(*secret, timestamp, payload, r.Header.Get("X-Webhook-Signature")) {
+			log.Printf("rejected delivery %s: invalid signature", r.Header.Get("X-Webhook-Delivery"))
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		log.Printf("delivery %s (%s): %s", r.Header.Get("X-Webhook-Delivery"), r.Header.Get("X-Webhook-Event"), payload)
+		fmt.Fprintln(w, "ok")
+	})
+
+	log.Println("listening for webhooks on", *addr)
+	return http.ListenAndServe(*addr, nil)
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type APIKeyController struct{}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create a key for a machine integration. Scopes are permission names and can only include permissions the caller holds. The key is only returned by this call; send it in the X-API-Key header.
+// @Tags API Keys
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param key body models.APIKeyRequest true "API key"
+// @Success 200 {object} models.APIKeyCreatedResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api-keys [post]
+func (kc *APIKeyController) CreateAPIKey(c echo.Context) error {
+	callerID := utils.CallerID(c)
+
+	var request models.APIKeyRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+	for _, scope := range request.Scopes {
+		if _, ok := models.Permissions[scope]; !ok {
+			return apperror.ErrUnknownScope.WithMessage("Unknown scope " + scope)
+		}
+		if !utils.HasPermission(c, scope) {
+			return apperror.ErrScopeNotHeld.WithMessage("You cannot grant a scope you do not hold: " + scope)
+		}
+	}
+	if err := utils.ValidateAllowedIPs(request.AllowedIPs); err != nil {
+		return apperror.Invalid(err)
+	}
+	if request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		return apperror.BadRequest("Expiry must be in the future")
+	}
+
+	key, err := utils.GenerateAPIKey()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	apiKey := models.APIKey{
+		Name:        request.Name,
+		Prefix:      utils.APIKeyDisplayPrefix(key),
+		KeyHash:     utils.HashToken(key),
+		Scopes:      strings.Join(request.Scopes, ","),
+		AllowedIPs:  strings.Join(request.AllowedIPs, ","),
+		ExpiresAt:   request.ExpiresAt,
+		CreatedByID: callerID,
+	}
+	if err := db.Create(&apiKey).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.APIKeyCreatedResponse{APIKey: apiKey, Key: key})
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description List API keys with their scopes and last use, including revoked ones
+// @Tags API Keys
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api-keys [get]
+func (kc *APIKeyController) GetAPIKeys(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var keys []models.APIKey
+	if err := db.Order("id").Find(&keys).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke a key immediately. The record is kept for reference.
+// @Tags API Keys
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api-keys/{id} [delete]
+func (kc *APIKeyController) RevokeAPIKey(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var apiKey models.APIKey
+	if err := db.First(&apiKey, c.Param("id")).Error; err != nil {
+		return apperror.ErrAPIKeyNotFound
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := db.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+			return apperror.Internal(err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, apiKey)
+}
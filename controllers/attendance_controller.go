@@ -1,16 +1,17 @@
 package controllers
 
 import (
+	"attendance/apperror"
 	"attendance/models"
 	"attendance/utils"
 	"fmt"
-	"log"
 	"net/http"
-	"net/smtp"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AttendanceController struct{}
@@ -23,34 +24,57 @@ type AttendanceController struct{}
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID, used by API key callers; employees always clock themselves"
 // @Success 200 {object} models.ClockResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "ATTENDANCE_ALREADY_CLOCKED_IN"
 // @Failure 500 {object} models.ErrorResponse
 // @Router /attendance/clock-in/{id} [post]
 func (ac *AttendanceController) ClockIn(c echo.Context) error {
-	employeeID, _, err := utils.ExtractData(c)
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
-	db, err := utils.Connect()
+	employee, err := clockEmployee(c, db)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.ErrEmployeeNotFound
 	}
+	if !employee.CanWork(time.Now()) {
+		return apperror.ErrAccountInactive
+	}
+	employeeID := int(employee.ID)
 
 	clockIn := models.ClockIn{EmployeeID: employeeID, ClockInTime: time.Now()}
-	if err := db.Create(&clockIn).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// lock the employee so two clock-ins cannot both find no open session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Take(&models.Employee{}, employee.ID).Error; err != nil {
+			return err
+		}
+		session, err := utils.OpenSession(tx, employeeID)
+		if err != nil {
+			return err
+		}
+		if session != nil {
+			return apperror.ErrAlreadyClockedIn
+		}
+		return tx.Create(&clockIn).Error
+	})
+	if err != nil {
+		return apperror.From(err)
 	}
 
-	// go ac.sendClockOutReminder(clockIn)
-
-	return c.JSON(http.StatusOK, models.ClockResponse{
+	response := models.ClockResponse{
 		ID:         clockIn.ID,
 		EmployeeID: clockIn.EmployeeID,
 		ClockType:  "clock_in",
-		ClockTime:  time.Now(),
-	})
+		ClockTime:  clockIn.ClockInTime,
+	}
+	utils.PublishEvent(db, models.EventClockIn, response)
+
+	return c.JSON(http.StatusOK, response)
 }
 
 // ClockOut
@@ -61,58 +85,61 @@ func (ac *AttendanceController) ClockIn(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID, used by API key callers; employees always clock themselves"
 // @Success 200 {object} models.ClockResponse
-// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "ATTENDANCE_NOT_CLOCKED_IN"
 // @Failure 500 {object} models.ErrorResponse
 // @Router /attendance/clock-out/{id} [post]
 func (ac *AttendanceController) ClockOut(c echo.Context) error {
-	employeeID, _, err := utils.ExtractData(c)
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
-	db, err := utils.Connect()
+	employee, err := clockEmployee(c, db)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.ErrEmployeeNotFound
 	}
+	employeeID := int(employee.ID)
 
-	var lastClockIn models.ClockIn
-	if err := db.Where("employee_id = ?", employeeID).Last(&lastClockIn).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
-	}
-
-	var existingClockOut models.ClockOut
-	if err := db.Where("employee_id = ? AND id = ?", employeeID, lastClockIn.ID).First(&lastClockIn).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+	session, err := utils.OpenSession(db, employeeID)
+	if err != nil {
+		return apperror.Internal(err)
 	}
-	if existingClockOut.ID != 0 {
-		return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "You have already clocked out for this shift"})
+	if session == nil {
+		return apperror.ErrNotClockedIn
 	}
 
-	clockOut := models.ClockOut{EmployeeID: employeeID, ClockInID: lastClockIn.ID, ClockOutTime: time.Now()}
+	clockOut := models.ClockOut{EmployeeID: employeeID, ClockInID: session.ClockInID, ClockOutTime: time.Now()}
 	if err := db.Create(&clockOut).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		if utils.IsDuplicateKey(err) {
+			// a concurrent clock-out closed the session first
+			return apperror.ErrNotClockedIn
+		}
+		return apperror.Internal(err)
 	}
 
-	// go ac.sendClockInReminder(clockOut.EmployeeID, clockOut.CreatedAt.AddDate(0, 0, 1))
-
-	hoursWorked := clockOut.CreatedAt.Sub(lastClockIn.CreatedAt)
+	hoursWorked := clockOut.ClockOutTime.Sub(session.ClockInTime)
 	hours := int(hoursWorked.Hours())
 	minutes := int(hoursWorked.Minutes()) % 60
 	workingHours := models.WorkingHours{EmployeeID: employeeID, HoursWorked: fmt.Sprintf("%d hour(s) %d minute(s)", hours, minutes)}
 	if err := db.Create(&workingHours).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
-	return c.JSON(http.StatusOK, models.ClockResponse{
+	response := models.ClockResponse{
 		ID:         clockOut.ID,
 		EmployeeID: clockOut.EmployeeID,
 		ClockType:  "clock_out",
 		ClockTime:  clockOut.ClockOutTime,
 		Hours:      hours,
 		Minutes:    minutes,
-	})
+	}
+	utils.PublishEvent(db, models.EventClockOut, response)
+
+	return c.JSON(http.StatusOK, response)
 }
 
 // GetWorkHours godoc
@@ -130,24 +157,21 @@ func (ac *AttendanceController) ClockOut(c echo.Context) error {
 // @Failure 500 {object} models.ErrorResponse
 // @Router /attendance/work-hours/{id} [get]
 func (ac *AttendanceController) GetWorkHours(c echo.Context) error {
-	// Get employee ID from JWT token
-	employeeID, _, err := utils.ExtractData(c)
-	if err != nil {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
-	}
+	// Get employee ID of the authenticated caller
+	employeeID := utils.CallerID(c)
 
 	// Find all clock-in and clock-out entries for the employee
-	db, err := utils.Connect()
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 	var clockIns []models.ClockIn
 	if err := db.Where("employee_id = ?", employeeID).Find(&clockIns).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 	var clockOuts []models.ClockOut
 	if err := db.Where("employee_id = ?", employeeID).Find(&clockOuts).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
 	// Calculate the total number of hours worked
@@ -169,62 +193,90 @@ func (ac *AttendanceController) GetWorkHours(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-func (ac *AttendanceController) sendClockInReminder(employeeID int, clockInTime time.Time) {
-	// Find employee email address from the database
-	db, err := utils.Connect()
+// GetSessions godoc
+// @Summary List attendance sessions
+// @Description List clock-in/clock-out sessions of the employees visible to the caller: their reporting subtree, or everyone for HR
+// @Tags Attendance
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param employee_id query int false "Only sessions of this employee"
+// @Param from query string false "Earliest clock-in date (YYYY-MM-DD)"
+// @Param to query string false "Latest clock-in date (YYYY-MM-DD)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Number of items per page, at most 200" default(50)
+// @Success 200 {object} models.AttendanceSessionList
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /attendance/sessions [get]
+func (ac *AttendanceController) GetSessions(c echo.Context) error {
+	after, limit, err := utils.ParseCursor(c, "attendance-sessions")
 	if err != nil {
-		log.Println("Error connecting to database:", err)
-		return
+		return apperror.Invalid(err)
 	}
 
-	var employee models.Employee
-	result := db.First(&employee, employeeID)
-	if result.Error != nil {
-		log.Println("Error finding employee:", result.Error)
-		return
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
 	}
 
-	// Construct email message
-	to := []string{employee.Email}
-	subject := "Clock-in reminder for tomorrow"
-	body := fmt.Sprintf("Hi %s,\n\nThis is a reminder that your clock-in time is tomorrow at %s.\n\nBest regards,\nThe Attendance App", employee.Fullname, clockInTime.Format("15:04:05"))
-
-	// Set up SMTP client
-	auth := smtp.PlainAuth("", "sender@example.com", "password", "smtp.example.com")
-	msg := []byte("To: " + strings.Join(to, ",") + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"\r\n" + body + "\r\n")
+	query, err := utils.VisibleTo(c, utils.SessionQuery(db), "clock_ins.employee_id")
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if employeeID := c.QueryParam("employee_id"); employeeID != "" {
+		query = query.Where("clock_ins.employee_id = ?", employeeID)
+	}
+	if from := c.QueryParam("from"); from != "" {
+		date, err := utils.ParseDate(from)
+		if err != nil {
+			return apperror.BadRequest("Invalid from date")
+		}
+		query = query.Where("clock_ins.clock_in_time >= ?", date)
+	}
+	if to := c.QueryParam("to"); to != "" {
+		date, err := utils.ParseDate(to)
+		if err != nil {
+			return apperror.BadRequest("Invalid to date")
+		}
+		query = query.Where("clock_ins.clock_in_time < ?", date.AddDate(0, 0, 1))
+	}
 
-	// Send email
-	err = smtp.SendMail("smtp.example.com:587", auth, "sender@example.com", to, msg)
+	sessions, page, err := attendancePage(query, "attendance-sessions", after, limit)
 	if err != nil {
-		log.Println("Error sending email:", err)
+		return apperror.Internal(err)
 	}
+
+	return c.JSON(http.StatusOK, models.AttendanceSessionList{Data: sessions, CursorPagination: page})
 }
 
-func (ac *AttendanceController) sendClockOutReminder(clockIn models.ClockIn) {
-	// Get employee email address
-	db, err := utils.Connect()
+// attendancePage fetches a page of sessions, newest clock-in first.
+func attendancePage(query *gorm.DB, scope string, after *utils.Cursor, limit int) ([]models.AttendanceSession, models.CursorPagination, error) {
+	sessions, err := utils.AttendanceSessions(utils.CursorQuery(query, "clock_ins.clock_in_time", "clock_ins.id", after, limit))
 	if err != nil {
-		log.Println("Error connecting to database:", err.Error())
-		return
+		return nil, models.CursorPagination{}, err
 	}
-
-	var employee models.Employee
-	result := db.Where("id = ?", clockIn.EmployeeID).First(&employee)
-	if result.Error != nil {
-		log.Println("Error retrieving employee:", result.Error.Error())
-		return
+	if sessions == nil {
+		sessions = []models.AttendanceSession{}
 	}
+	n, page := utils.CursorPage(scope, limit, len(sessions), func(i int) utils.Cursor {
+		return utils.Cursor{Time: sessions[i].ClockInTime, ID: sessions[i].ClockInID}
+	})
+	return sessions[:n], page, nil
+}
 
-	// Construct email message
-	to := employee.Email
-	subject := "Reminder: Clock out time"
-	body := fmt.Sprintf("Hello %s,\n\nThis is a reminder that your clock-out time is tomorrow at %s.\n\nBest regards,\nThe Attendance System", employee.Fullname, clockIn.CreatedAt.Add(time.Hour*8).Format("15:04:05"))
-
-	// Send email using SMTP
-	err = utils.SendEmail(to, subject, body)
-	if err != nil {
-		log.Println("Error sending email:", err.Error())
+// clockEmployee returns the employee a clock request is for. Employees
+// always clock themselves; API key callers, such as badge readers, name the
+// employee in the :id path parameter.
+func clockEmployee(c echo.Context, db *gorm.DB) (models.Employee, error) {
+	id := c.Param("id")
+	if principal := utils.CurrentPrincipal(c); principal == nil || !principal.IsAPIKey() {
+		id = strconv.Itoa(utils.CallerID(c))
 	}
+
+	var employee models.Employee
+	err := db.Select("id", "status", "termination_date").First(&employee, id).Error
+	return employee, err
 }
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type AuditController struct{}
+
+// GetAuditLogs godoc
+// @Summary Search the audit log
+// @Description List audit entries, newest first. from and to accept a date (YYYY-MM-DD, to is inclusive) or an RFC 3339 time.
+// @Tags Audit
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param actor_id query int false "Employee who made the change"
+// @Param actor_type query string false "employee, api_key, anonymous or system"
+// @Param entity query string false "Table name, e.g. employees"
+// @Param entity_id query string false "Primary key of the entity"
+// @Param action query string false "create, update or delete"
+// @Param request_id query string false "Request ID"
+// @Param from query string false "Start of the time range"
+// @Param to query string false "End of the time range"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Number of items per page, at most 200" default(50)
+// @Success 200 {object} models.AuditLogList
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /audit-logs [get]
+func (ac *AuditController) GetAuditLogs(c echo.Context) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+	after, limit, err := utils.ParseCursor(c, "audit-logs")
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	entries := []models.AuditLog{}
+	if err := utils.CursorQuery(utils.FilterAuditLogs(db, filter), "created_at", "id", after, limit).Find(&entries).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	n, page := utils.CursorPage("audit-logs", limit, len(entries), func(i int) utils.Cursor {
+		return utils.Cursor{Time: entries[i].CreatedAt, ID: entries[i].ID}
+	})
+
+	return c.JSON(http.StatusOK, models.AuditLogList{Data: entries[:n], CursorPagination: page})
+}
+
+// ExportAuditLogs godoc
+// @Summary Export the audit log as CSV
+// @Description Download every audit entry matching the filters as CSV, newest first
+// @Tags Audit
+// @Security ApiKeyAuth
+// @Produce text/csv
+// @Param Authorization header string true "Bearer {token}"
+// @Param actor_id query int false "Employee who made the change"
+// @Param actor_type query string false "employee, api_key, anonymous or system"
+// @Param entity query string false "Table name, e.g. employees"
+// @Param entity_id query string false "Primary key of the entity"
+// @Param action query string false "create, update or delete"
+// @Param request_id query string false "Request ID"
+// @Param from query string false "Start of the time range"
+// @Param to query string false "End of the time range"
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /audit-logs/export [get]
+func (ac *AuditController) ExportAuditLogs(c echo.Context) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	// stream the file in batches, the log can be large
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="audit-%s.csv"`, time.Now().Format("20060102-150405")))
+	c.Response().WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(c.Response())
+	if err := writer.Write(utils.AuditCSVHeader); err != nil {
+		return err
+	}
+	var lastID uint
+	for {
+		query := utils.FilterAuditLogs(db, filter).Order("id DESC")
+		if lastID != 0 {
+			query = query.Where("id < ?", lastID)
+		}
+		var batch []models.AuditLog
+		if err := query.Limit(1000).Find(&batch).Error; err != nil {
+			return err
+		}
+		for _, entry := range batch {
+			if err := writer.Write(utils.AuditCSVRow(entry)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil || len(batch) < 1000 {
+			return err
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+func auditFilter(c echo.Context) (utils.AuditFilter, error) {
+	filter := utils.AuditFilter{
+		ActorType: c.QueryParam("actor_type"),
+		Entity:    c.QueryParam("entity"),
+		EntityID:  c.QueryParam("entity_id"),
+		Action:    c.QueryParam("action"),
+		RequestID: c.QueryParam("request_id"),
+	}
+	if param := c.QueryParam("actor_id"); param != "" {
+		id, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return filter, errors.New("Invalid actor_id")
+		}
+		filter.ActorID = uint(id)
+	}
+
+	var err error
+	if filter.From, err = auditTime(c.QueryParam("from"), false); err != nil {
+		return filter, errors.New("Invalid from")
+	}
+	if filter.To, err = auditTime(c.QueryParam("to"), true); err != nil {
+		return filter, errors.New("Invalid to")
+	}
+	return filter, nil
+}
+
+// auditTime parses a date or RFC 3339 time. A date used as the end of a
+// range includes the whole day.
+func auditTime(param string, end bool) (time.Time, error) {
+	if param == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, param); err == nil {
+		return t, nil
+	}
+	day, err := utils.ParseDate(param)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
@@ -1,86 +1,336 @@
 package controllers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
+	"attendance/apperror"
 	"attendance/models"
 	"attendance/utils"
 )
 
 type AuthController struct{}
 
+// dummyPasswordHash is compared against when the username is unknown, so
+// such logins take as long as a wrong password and do not reveal which
+// usernames exist. It uses the cost of real password hashes.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
 // Login godoc
 // @Summary Login to the system
-// @Description Login to the system with username and password
+// @Description Login to the system with username and password. Accounts with two-factor authentication, or whose role requires it, get a 202 challenge to complete at /login/2fa instead of tokens.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param loginData body  models.LoginData true "Login Data"
 // @Success 200 {object} models.TokenResponse
+// @Success 202 {object} models.TwoFactorChallengeResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /login [post]
 func (auth *AuthController) Login(c echo.Context) error {
 	var loginData models.LoginData
 
 	if err := c.Bind(&loginData); err != nil {
-		return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&loginData); err != nil {
+		return err
 	}
 
-	db, err := utils.Connect()
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Database connection error"})
+		return apperror.Internal(err)
+	}
+
+	if err := checkLoginAllowed(c, db, loginData.Username); err != nil {
+		return err
 	}
 
 	var user models.Employee
 	result := db.Where("username = ?", loginData.Username).First(&user)
 	if result.Error != nil {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid username or password"})
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(loginData.Password))
+		loginFailed(c, db, loginData.Username, nil)
+		return apperror.ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password)); err != nil {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid username or password"})
+		loginFailed(c, db, loginData.Username, &user)
+		return apperror.ErrInvalidCredentials
+	}
+	if !user.CanWork(time.Now()) {
+		return apperror.ErrAccountInactive
+	}
+
+	// failures are only cleared once the second factor passed too
+	if challenge, err := twoFactorChallenge(db, user); err != nil {
+		return apperror.Internal(err)
+	} else if challenge != nil {
+		return c.JSON(http.StatusAccepted, challenge)
+	}
+	if err := utils.RecordLoginSuccess(db, loginData.Username); err != nil {
+		log.Println("Error clearing failed logins:", err)
+	}
+
+	response, err := utils.StartSession(db, user, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete a two-factor login
+// @Description Finish a login that returned a challenge with a code from the authenticator app or a recovery code. When the challenge was for enrollment, the code enables 2FA and the response includes the recovery codes.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param challenge body models.TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /login/2fa [post]
+func (auth *AuthController) LoginTwoFactor(c echo.Context) error {
+	var request models.TwoFactorLoginRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	challenge, user, err := utils.FindLoginChallenge(db, request.ChallengeToken)
+	if err != nil {
+		return apperror.ErrInvalidChallenge
+	}
+	if err := checkLoginAllowed(c, db, user.Username); err != nil {
+		return err
 	}
 
-	token, err := utils.GenerateToken(int(user.ID), user.Role)
+	recoveryCodes, err := utils.CompleteLoginChallenge(db, challenge, request.Code)
+	if errors.Is(err, utils.ErrInvalidChallenge) {
+		return apperror.ErrInvalidChallenge
+	}
+	if errors.Is(err, utils.ErrInvalidTwoFactorCode) {
+		loginFailed(c, db, user.Username, &user)
+		return apperror.ErrInvalidTwoFactorCode.WithStatus(http.StatusUnauthorized)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Token generation error"})
+		return apperror.Internal(err)
+	}
+	if err := utils.RecordLoginSuccess(db, user.Username); err != nil {
+		log.Println("Error clearing failed logins:", err)
 	}
 
-	response := models.TokenResponse{
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
-		Token:    token,
+	response, err := utils.StartSession(db, user, c.Request().UserAgent(), c.RealIP())
+	if errors.Is(err, utils.ErrEmployeeInactive) {
+		return apperror.ErrAccountInactive
+	}
+	if err != nil {
+		return apperror.Internal(err)
 	}
+	response.RecoveryCodes = recoveryCodes
 
 	return c.JSON(http.StatusOK, response)
 }
 
+// checkLoginAllowed answers logins of a throttled username or IP with
+// apperror.ErrLoginThrottled and a Retry-After header.
+func checkLoginAllowed(c echo.Context, db *gorm.DB, username string) error {
+	var throttled *utils.ThrottleError
+	if err := utils.CheckLoginAllowed(db, username, c.RealIP()); errors.As(err, &throttled) {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		return apperror.ErrLoginThrottled.WithMessage(throttled.Error())
+	} else if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// loginFailed counts a failed login and, when it locks the account, tells
+// the owner by email.
+func loginFailed(c echo.Context, db *gorm.DB, username string, user *models.Employee) {
+	locked, err := utils.RecordLoginFailure(db, username, c.RealIP())
+	if err != nil {
+		log.Println("Error recording failed login:", err)
+		return
+	}
+	if !locked || user == nil {
+		return
+	}
+
+	err = utils.Notify(db, models.EventAccountLocked, user.Email, map[string]interface{}{
+		"Fullname":  user.Fullname,
+		"IP":        c.RealIP(),
+		"LockedFor": utils.LoginLockout().String(),
+	})
+	if err != nil {
+		log.Println("Error queueing account locked email:", err)
+	}
+}
+
+// twoFactorChallenge returns the second login step the employee still has
+// to pass, or nil when the password is enough.
+func twoFactorChallenge(db *gorm.DB, user models.Employee) (*models.TwoFactorChallengeResponse, error) {
+	enabled, err := utils.EnabledTwoFactor(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge models.TwoFactorChallengeResponse
+	if enabled != nil {
+		challenge.TwoFactor = models.TwoFactorRequired
+	} else {
+		required, err := utils.TwoFactorRequired(db, user.ID)
+		if err != nil || !required {
+			return nil, err
+		}
+		enrollment, err := utils.BeginTwoFactorEnrollment(db, user)
+		if err != nil {
+			return nil, err
+		}
+		challenge.TwoFactor = models.TwoFactorEnrollmentRequired
+		challenge.Secret = enrollment.Secret
+		challenge.ProvisioningURI = enrollment.ProvisioningURI
+	}
+
+	challenge.ChallengeToken, err = utils.CreateLoginChallenge(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RefreshToken godoc
+// @Summary Refresh an access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one logs out its session.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /token/refresh [post]
+func (auth *AuthController) RefreshToken(c echo.Context) error {
+	var request models.RefreshRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if request.RefreshToken == "" {
+		return apperror.BadRequest("Refresh token is required")
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	response, err := utils.RefreshSession(db, request.RefreshToken)
+	if errors.Is(err, utils.ErrInvalidRefreshToken) {
+		return apperror.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the session of the access token, together with its refresh token
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} models.MessageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /logout [post]
+func (auth *AuthController) Logout(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	if err := utils.RevokeSession(db, utils.CurrentPrincipal(c).SessionID); err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Logged out"})
+}
+
+// LogoutAll godoc
+// @Summary Logout everywhere
+// @Description Revoke every session of the caller, on all devices
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} models.MessageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /logout-all [post]
+func (auth *AuthController) LogoutAll(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	if err := utils.RevokeSessions(db, uint(employeeID)); err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Logged out of all sessions"})
+}
+
 // Register godoc
 // @Summary Register to the system
 // @Description Register to the system with username, password, email, and isAdmin flag
 // @Tags Auth
 // @Accept json
 // @Produce json
-// @Param registrationData body  models.Employee true "Registration Data"
-// @Success 200 {object} models.CreateEmployeeResponse
+// @Param registrationData body  models.RegisterRequest true "Registration Data"
+// @Success 200 {object} models.ProfileResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /register [post]
 func (auth *AuthController) Register(c echo.Context) error {
-	db, err := utils.Connect()
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
-	var customer models.Employee
+	var customer models.RegisterRequest
 	if err := c.Bind(&customer); err != nil {
-		return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&customer); err != nil {
+		return err
 	}
 
 	// Check if username and email already exist
@@ -88,17 +338,17 @@ func (auth *AuthController) Register(c echo.Context) error {
 	result := db.Where("username = ?", customer.Username).Or("email = ?", customer.Email).First(&existingUser)
 	if result.Error == nil {
 		if existingUser.Username == customer.Username {
-			return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Username already exists"})
+			return apperror.ErrUsernameTaken
 		}
 		if existingUser.Email == customer.Email {
-			return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Email already exists"})
+			return apperror.ErrEmailTaken
 		}
 	}
 
 	// Hash the password
 	hash, err := bcrypt.GenerateFromPassword([]byte(customer.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Password hashing error"})
+		return apperror.Internal(err)
 	}
 
 	// Create a new user record
@@ -110,21 +360,116 @@ func (auth *AuthController) Register(c echo.Context) error {
 		PhoneNumber: customer.PhoneNumber,
 		Address:     customer.Address,
 		Role:        "user",
+		Status:      models.EmployeeActive,
 	}
 
 	if err := db.Create(&newCustomer).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
+	}
+	if err := utils.AssignRoles(db, &newCustomer, []string{models.RoleEmployee}); err != nil {
+		return apperror.Internal(err)
 	}
+	utils.PublishEvent(db, models.EventEmployeeCreated, employeeEventData(newCustomer))
+
+	return c.JSON(http.StatusOK, profileResponse(newCustomer, []string{models.RoleEmployee}))
+}
 
-	response := models.CreateEmployeeResponse{
-		Fullname:    newCustomer.Fullname,
-		Username:    newCustomer.Username,
-		Email:       newCustomer.Email,
-		Password:    customer.Password,
-		Role:        newCustomer.Role,
-		PhoneNumber: newCustomer.PhoneNumber,
-		Address:     newCustomer.Address,
+// RequestPasswordReset godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset link. The response is the same whether or not the email is registered.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetRequest true "Account email"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Router /password-reset/request [post]
+func (auth *AuthController) RequestPasswordReset(c echo.Context) error {
+	var request models.PasswordResetRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	response := models.MessageResponse{Message: "If the email is registered, a password reset link has been sent"}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		log.Println("Error connecting to database:", err)
+		return c.JSON(http.StatusOK, response)
+	}
+
+	var user models.Employee
+	if err := db.Where("email = ?", request.Email).First(&user).Error; err != nil || !user.CanWork(time.Now()) {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	token, err := utils.IssuePasswordReset(db, user.ID)
+	if err != nil {
+		log.Println("Error issuing password reset:", err)
+		return c.JSON(http.StatusOK, response)
+	}
+
+	err = utils.Notify(db, models.EventPasswordReset, user.Email, map[string]interface{}{
+		"Fullname":  user.Fullname,
+		"Link":      utils.PasswordResetLink(token),
+		"ExpiresIn": utils.PasswordResetTTL().String(),
+	})
+	if err != nil {
+		log.Println("Error queueing password reset email:", err)
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
+
+// ConfirmPasswordReset godoc
+// @Summary Reset a password
+// @Description Set a new password with a token from a password reset email. Every token issued before the reset stops working.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetConfirmRequest true "Reset token and new password"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /password-reset/confirm [post]
+func (auth *AuthController) ConfirmPasswordReset(c echo.Context) error {
+	var request models.PasswordResetConfirmRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	if err := utils.ResetPassword(db, request.Token, request.NewPassword); err != nil {
+		if errors.Is(err, utils.ErrInvalidResetToken) {
+			return apperror.ErrInvalidResetToken
+		}
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Password has been reset"})
+}
+
+// JWKS serves the public keys access tokens are signed with at
+// /.well-known/jwks.json, outside the versioned API. Tokens name their key
+// in the kid header.
+func (auth *AuthController) JWKS(c echo.Context) error {
+	ring, err := utils.Keys()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, ring.JWKS())
+}
@@ -1,55 +1,85 @@
 package controllers
 
 import (
+	"attendance/apperror"
 	"attendance/models"
 	"attendance/utils"
+	"bytes"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EmployeeController struct{}
 
 // GetEmployees godoc
-// @Summary Get a list of employees
-// @Description Get a list of employees with pagination
+// @Summary List and search employees
+// @Description List employees a page at a time. q searches fullname, username and email, and phone number for callers with employees.personal; every word must match. The filters take comma separated values.
 // @Tags Employees
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer {token}"
-// @Param page query int true "Page number"
-// @Param limit query int true "Number of items per page"
-// @Success 200 {object} models.Employee
+// @Param q query string false "Search text"
+// @Param role query string false "Role names"
+// @Param department query string false "Department IDs"
+// @Param status query string false "Statuses (active, suspended, terminated) or all; defaults to active"
+// @Param location query string false "Locations"
+// @Param sort query string false "Sort fields (fullname, username, email, location, status, hire_date, created_at, id), - for descending" default(fullname)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Number of items per page, at most 200" default(50)
+// @Success 200 {object} models.EmployeeList
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /employees [get]
 func (controller EmployeeController) GetEmployees(c echo.Context) error {
-	_, role, err := utils.ExtractData(c)
-
-	if role != "admin" {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Only admin can Access"})
+	page, limit, err := utils.ParsePage(c)
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+	order, err := utils.ParseSort(c.QueryParam("sort"), employeeSortColumns, "fullname")
+	if err != nil {
+		return apperror.Invalid(err)
 	}
 
-	db, err := utils.Connect()
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Database connection error"})
+		return apperror.Internal(err)
 	}
 
-	var employees []models.Employee
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	offset := (page - 1) * limit
+	query, err := utils.VisibleTo(c, db, "id")
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	query, err = employeeFilters(c, query)
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+	// the filtered query is used twice, for the count and for the page
+	query = query.Session(&gorm.Session{})
 
-	result := db.Offset(offset).Limit(limit).Find(&employees)
+	var total int64
+	if err := query.Model(&models.Employee{}).Count(&total).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	employees := []models.Employee{}
+	result := query.Clauses(clause.OrderBy{Columns: order}).Offset((page - 1) * limit).Limit(limit).Find(&employees)
 	if result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return apperror.Internal(result.Error)
 	}
 
-	return c.JSON(http.StatusOK, employees)
+	return c.JSON(http.StatusOK, models.EmployeeList{Data: employeeResponses(c, employees), Pagination: utils.PageInfo(c, page, limit, total)})
 }
 
 // @Summary Get a employee
@@ -60,206 +90,722 @@ func (controller EmployeeController) GetEmployees(c echo.Context) error {
 // @Produce json
 // @Param Authorization header string true "Bearer {token}"
 // @Param id path int true "Employee ID"
-// @Success 200 {object} models.Employee
+// @Success 200 {object} models.EmployeeResponse
+// @Header 200 {string} ETag "Version of the employee, for If-Match"
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /employees/{id} [get]
 func (controller EmployeeController) GetEmployee(c echo.Context) error {
-	_, role, err := utils.ExtractData(c)
-
-	if role != "admin" {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Only admin can Access"})
-	}
-	fmt.Println(role)
-	db, err := utils.Connect()
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
-	var employee models.Employee
-	result := db.First(&employee, c.Param("id"))
-	if result.Error != nil {
-		return c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Employee not found"})
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
 	}
 
-	return c.JSON(http.StatusOK, employee)
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
 }
 
 // @Summary Create a employee
 // @Description Create a new employee
 // @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
 // @Accept json
 // @Produce json
-// @Param employee body models.Employee true "Employee object"
-// @Success 200 {object} models.CreateEmployeeResponse
+// @Param employee body models.CreateEmployeeRequest true "Employee object"
+// @Success 200 {object} models.EmployeeResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /employees [post]
 func (controller EmployeeController) CreateEmployee(c echo.Context) error {
-	// all user can access
-	db, err := utils.Connect()
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
-	var employee models.Employee
-	err = c.Bind(&employee)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+	var request models.CreateEmployeeRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
 	}
 
 	//cek
 	var existingUser models.Employee
-	checkUsername := db.Where("username = ?", employee.Username).First(&existingUser)
+	checkUsername := db.Where("username = ?", request.Username).First(&existingUser)
 	if checkUsername.Error == nil {
-		return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Username already exists"})
+		return apperror.ErrUsernameTaken
 	}
-	checkEmail := db.Where("email = ?", employee.Email).First(&existingUser)
+	checkEmail := db.Where("email = ?", request.Email).First(&existingUser)
 	if checkEmail.Error == nil {
-		return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Email already exists"})
+		return apperror.ErrEmailTaken
 	}
 
-	employee.Role = "user"
-
-	hash, err := bcrypt.GenerateFromPassword([]byte(employee.Password), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Password hashing error"})
+		return apperror.Internal(err)
 	}
-
-	newEmployee := models.Employee{
-		Fullname:    employee.Fullname,
-		Username:    employee.Username,
-		Password:    string(hash),
-		Email:       employee.Email,
-		PhoneNumber: employee.PhoneNumber,
-		Address:     employee.Address,
-		Role:        employee.Role}
-
-	result := db.Create(&newEmployee)
-	if result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	employee, err := newEmployee(request, string(hash))
+	if err != nil {
+		return apperror.Invalid(err)
 	}
 
-	response := models.CreateEmployeeResponse{
-		Fullname:    newEmployee.Fullname,
-		Username:    newEmployee.Username,
-		Password:    employee.Password,
-		Email:       newEmployee.Email,
-		Role:        newEmployee.Role,
-		PhoneNumber: newEmployee.PhoneNumber,
-		Address:     newEmployee.Address,
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&employee).Error; err != nil {
+			return err
+		}
+		return utils.AssignRoles(tx, &employee, []string{models.RoleEmployee})
+	})
+	if utils.IsDuplicateKey(err) {
+		// another request took the username or email since the checks above
+		if db.Where("username = ?", request.Username).First(&existingUser).Error == nil {
+			return apperror.ErrUsernameTaken
+		}
+		return apperror.ErrEmailTaken
+	}
+	if err != nil {
+		return apperror.Internal(err)
 	}
+	utils.PublishEvent(db, models.EventEmployeeCreated, employeeEventData(employee))
 
-	return c.JSON(http.StatusOK, response)
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
 }
 
 // UpdateEmployee godoc
 // @Summary Update a employee by ID
-// @Description Update a employee by ID
+// @Description Update a employee by ID. Fields left out of the body keep their value. Unknown fields and fields with their own endpoint (password, roles, status, organization) are rejected.
 // @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
 // @Param id path int true "Employee ID"
+// @Param If-Match header string true "ETag from GET /employees/{id}"
 // @Accept json
 // @Produce json
-// @Param employee body models.Employee true "Employee data"
-// @Success 200 {object} models.Employee
+// @Param employee body models.UpdateEmployeeRequest true "Employee data"
+// @Success 200 {object} models.EmployeeResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /employees/{id} [put]
 func (controller EmployeeController) UpdateEmployee(c echo.Context) error {
-	// all user can access
-	db, err := utils.Connect()
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEApplicationJSON {
+		return echo.ErrUnsupportedMediaType
+	}
+
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
-	var employee models.Employee
-	result := db.First(&employee, c.Param("id"))
-	if result.Error != nil {
-		return c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Employee not found"})
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := utils.CheckIfMatch(c, employee.Version); err != nil {
+		return err
 	}
 
-	err = c.Bind(&employee)
+	body, err := utils.ReadJSONBody(c)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return err
+	}
+	request := updateEmployeeRequest(employee)
+	if err := utils.DecodeJSON(&request, body, employeeReadOnlyFields); err != nil {
+		return err
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
 	}
 
-	result = db.Save(&employee)
-	if result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	if err := saveEmployeeUpdate(db, &employee, request); err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, employee)
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
+}
+
+// employeeReadOnlyFields are the fields PUT and PATCH reject, with where to
+// change them instead.
+var employeeReadOnlyFields = map[string]string{
+	"id":              "cannot be changed",
+	"createdAt":       "cannot be changed",
+	"updatedAt":       "cannot be changed",
+	"version":         "send the ETag in If-Match instead",
+	"password":        "use PUT /me/password or POST /employees/{id}/password-reset",
+	"role":            "use PUT /employees/{id}/roles",
+	"roles":           "use PUT /employees/{id}/roles",
+	"status":          "use the terminate, suspend and restore endpoints",
+	"terminationDate": "use the terminate, suspend and restore endpoints",
+	"departmentId":    "use PUT /employees/{id}/organization",
+	"teamId":          "use PUT /employees/{id}/organization",
+	"managerId":       "use PUT /employees/{id}/organization",
+}
+
+// employeeSelfFields are the fields employees may patch on themselves.
+var employeeSelfFields = map[string]bool{"phoneNumber": true, "address": true}
+
+// PatchEmployee godoc
+// @Summary Partially update a employee
+// @Description Change only the fields in the body, following JSON Merge Patch (RFC 7396): null clears a field. Holders of employees.update may patch every field of models.UpdateEmployeeRequest; employees may patch their own phoneNumber and address. Unknown fields and fields with their own endpoint (password, roles, status, organization) are rejected.
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Param If-Match header string true "ETag from GET /employees/{id}"
+// @Accept json
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param employee body models.UpdateEmployeeRequest true "Fields to change"
+// @Success 200 {object} models.EmployeeResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "FIELD_NOT_ALLOWED lists the fields in details"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id} [patch]
+func (controller EmployeeController) PatchEmployee(c echo.Context) error {
+	canUpdate := utils.HasPermission(c, models.PermEmployeesUpdate)
+	principal := utils.CurrentPrincipal(c)
+	self := principal != nil && !principal.IsAPIKey() && c.Param("id") == strconv.Itoa(principal.EmployeeID)
+	if !canUpdate && !self {
+		return apperror.ErrForbidden
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEApplicationJSON && mediaType != utils.MergePatchContentType {
+		return echo.ErrUnsupportedMediaType
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := utils.CheckIfMatch(c, employee.Version); err != nil {
+		return err
+	}
+
+	body, err := utils.ReadJSONBody(c)
+	if err != nil {
+		return err
+	}
+	request := updateEmployeeRequest(employee)
+	fields, err := utils.MergePatch(&request, body, employeeReadOnlyFields)
+	if err != nil {
+		return err
+	}
+	if !canUpdate {
+		var denied []string
+		for _, field := range fields {
+			if !employeeSelfFields[field] {
+				denied = append(denied, field)
+			}
+		}
+		if len(denied) > 0 {
+			return apperror.ErrFieldNotAllowed.WithDetails(denied)
+		}
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	if err := saveEmployeeUpdate(db, &employee, request); err != nil {
+		return err
+	}
+
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
+}
+
+// saveEmployeeUpdate applies a validated update request to the employee and
+// saves the changed columns, keeping usernames and emails unique.
+func saveEmployeeUpdate(db *gorm.DB, employee *models.Employee, request models.UpdateEmployeeRequest) error {
+	var taken int64
+	if err := db.Model(&models.Employee{}).Where("username = ? AND id <> ?", request.Username, employee.ID).Count(&taken).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	if taken > 0 {
+		return apperror.ErrUsernameTaken
+	}
+	if err := db.Model(&models.Employee{}).Where("email = ? AND id <> ?", request.Email, employee.ID).Count(&taken).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	if taken > 0 {
+		return apperror.ErrEmailTaken
+	}
+
+	if err := applyEmployeeUpdate(employee, request); err != nil {
+		return apperror.Invalid(err)
+	}
+	if err := utils.SaveEmployee(db, employee, "username", "fullname", "email", "phone_number", "address", "location", "hire_date"); err != nil {
+		return apperror.From(err)
+	}
+	return nil
 }
 
 // DeleteEmployee godoc
-// @Summary Delete a employee by ID
-// @Description Delete a employee by ID
+// @Summary Terminate a employee by ID
+// @Description Terminate a employee effective today. The employee record and its history are kept.
 // @Tags Employees
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Bearer {token}"
 // @Param id path int true "Employee ID"
+// @Param If-Match header string true "ETag from GET /employees/{id}"
 // @Produce json
 // @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /employees/{id} [delete]
 func (controller EmployeeController) DeleteEmployee(c echo.Context) error {
-	_, role, err := utils.ExtractData(c)
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
 
-	if role != "admin" {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Only admin can Access"})
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := utils.CheckIfMatch(c, employee.Version); err != nil {
+		return err
 	}
-	db, err := utils.Connect()
+	if employee.Status == models.EmployeeTerminated {
+		return apperror.ErrAlreadyTerminated
+	}
+
+	if err := utils.TerminateEmployee(db, &employee, time.Now()); err != nil {
+		return apperror.From(err)
+	}
+	utils.PublishEvent(db, models.EventEmployeeTerminated, employeeEventData(employee))
+	utils.PublishEvent(db, models.EventEmployeeDeleted, employeeEventData(employee))
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Employee Terminated Succesfully"})
+}
+
+// TerminateEmployee godoc
+// @Summary Terminate an employee
+// @Description End an employee's employment. The employee can log in and clock in until the end of the termination date, which defaults to today; attendance and payroll history are kept.
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Accept json
+// @Produce json
+// @Param termination body models.TerminationRequest false "Last working day"
+// @Success 200 {object} models.EmployeeResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/terminate [post]
+func (controller EmployeeController) TerminateEmployee(c echo.Context) error {
+	var request models.TerminationRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+	date := time.Now()
+	if request.Date != "" {
+		parsed, err := utils.ParseDate(request.Date)
+		if err != nil {
+			return apperror.BadRequest("Invalid date, expected YYYY-MM-DD")
+		}
+		date = parsed
+	}
+
+	db, err := utils.RequestDB(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.Internal(err)
 	}
 
-	var employee models.Employee
-	result := db.First(&employee, c.Param("id"))
-	if result.Error != nil {
-		return c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Employee not found"})
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
 	}
 
-	result = db.Delete(&employee)
-	if result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	err = utils.TerminateEmployee(db, &employee, date)
+	if errors.Is(err, utils.ErrInvalidTerminationDate) {
+		return apperror.Invalid(err)
 	}
+	if err != nil {
+		return apperror.From(err)
+	}
+	utils.PublishEvent(db, models.EventEmployeeTerminated, employeeEventData(employee))
 
-	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Employee Deleted Succesfully"})
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
+}
+
+// SuspendEmployee godoc
+// @Summary Suspend an employee
+// @Description Block an employee from logging in and clocking in until they are restored
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Produce json
+// @Success 200 {object} models.EmployeeResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/suspend [post]
+func (controller EmployeeController) SuspendEmployee(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+
+	if err := utils.SuspendEmployee(db, &employee); err != nil {
+		return apperror.From(err)
+	}
+	utils.PublishEvent(db, models.EventEmployeeSuspended, employeeEventData(employee))
+
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
+}
+
+// RestoreEmployee godoc
+// @Summary Restore an employee
+// @Description Reactivate a suspended employee or undo a termination
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Produce json
+// @Success 200 {object} models.EmployeeResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/restore [post]
+func (controller EmployeeController) RestoreEmployee(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if employee.Status == models.EmployeeActive {
+		return apperror.ErrAlreadyActive
+	}
+
+	if err := utils.RestoreEmployee(db, &employee); err != nil {
+		return apperror.From(err)
+	}
+	utils.PublishEvent(db, models.EventEmployeeRestored, employeeEventData(employee))
+
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
 }
 
 // SearchEmployees godoc
-// @Summary Search employees by name
-// @Description Search employees by name
+// @Summary Search employees
+// @Description Same as GET /employees, with the search text in query instead of q
 // @Tags Employees
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer {token}"
 // @Param query query string true "Search query"
-// @Success 200 {array} models.Employee
+// @Param status query string false "Statuses (active, suspended, terminated) or all; defaults to active"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Number of items per page, at most 200" default(50)
+// @Success 200 {object} models.EmployeeList
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Deprecated
 // @Router /employees/search [get]
 func (controller EmployeeController) SearchEmployees(c echo.Context) error {
-	_, role, err := utils.ExtractData(c)
+	return controller.GetEmployees(c)
+}
 
-	if role != "admin" {
-		return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Only admin can access"})
+// UnlockEmployee godoc
+// @Summary Unlock an employee account
+// @Description Lift a lockout caused by failed logins
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Produce json
+// @Success 200 {object} models.MessageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/unlock [post]
+func (controller EmployeeController) UnlockEmployee(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
 	}
-	db, err := utils.Connect()
+
+	employee, err := findVisibleEmployee(c, db)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return apperror.ErrEmployeeNotFound
 	}
 
-	var employees []models.Employee
-	query := "%" + c.QueryParam("query") + "%"
+	if err := utils.UnlockAccount(db, employee.Username); err != nil {
+		return apperror.Internal(err)
+	}
 
-	result := db.Where("name LIKE ?", query).Find(&employees)
-	if result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Account unlocked"})
+}
+
+// SendPasswordReset godoc
+// @Summary Send an employee a password reset link
+// @Description Email the employee a single-use link to choose a new password. Administrators never set passwords themselves.
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Produce json
+// @Success 200 {object} models.MessageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/password-reset [post]
+func (controller EmployeeController) SendPasswordReset(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
 	}
+	if !employee.CanWork(time.Now()) {
+		return apperror.ErrAccountInactive
+	}
+
+	token, err := utils.IssuePasswordReset(db, employee.ID)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	err = utils.Notify(db, models.EventPasswordReset, employee.Email, map[string]interface{}{
+		"Fullname":  employee.Fullname,
+		"Link":      utils.PasswordResetLink(token),
+		"ExpiresIn": utils.PasswordResetTTL().String(),
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Password reset link sent"})
+}
+
+// ImportEmployees godoc
+// @Summary Import employees from CSV
+// @Description Create or update employees from a CSV file, matching on username. Every row is checked first; when any row is invalid, or with dry_run=true, nothing is written and the report says what would happen; invalid rows are answered with EMPLOYEE_IMPORT_INVALID_ROWS and the report in details. New employees are emailed an invitation to choose their password.
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV file, see /employees/import/template"
+// @Param dry_run query bool false "Only validate the file"
+// @Success 200 {object} models.EmployeeImportReport
+// @Failure 400 {object} models.ErrorResponse "details hold the models.EmployeeImportReport when rows are invalid"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/import [post]
+func (controller EmployeeController) ImportEmployees(c echo.Context) error {
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+	header, err := c.FormFile("file")
+	if err != nil {
+		return apperror.BadRequest("A CSV file is required")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return apperror.ErrImportFile.Wrap(err)
+	}
+	defer file.Close()
 
-	return c.JSON(http.StatusOK, employees)
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	report, err := utils.ImportEmployees(db, file, dryRun)
+	if errors.Is(err, utils.ErrImportInvalid) {
+		return apperror.ErrImportRows.WithDetails(report)
+	}
+	if errors.Is(err, utils.ErrImportFile) {
+		return apperror.ErrImportFile.WithMessage(err.Error())
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// ImportTemplate godoc
+// @Summary Download the employee import template
+// @Description A CSV file with the import columns and an example row
+// @Tags Employees
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Bearer {token}"
+// @Produce text/csv
+// @Success 200 {string} string "CSV template"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /employees/import/template [get]
+func (controller EmployeeController) ImportTemplate(c echo.Context) error {
+	var out bytes.Buffer
+	if err := utils.WriteEmployeeImportTemplate(&out); err != nil {
+		return apperror.Internal(err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="employees-template.csv"`)
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", out.Bytes())
+}
+
+// findVisibleEmployee loads the employee named by the :id path parameter,
+// treating employees outside the caller's reporting subtree as missing.
+func findVisibleEmployee(c echo.Context, db *gorm.DB) (models.Employee, error) {
+	var employee models.Employee
+	if err := db.First(&employee, c.Param("id")).Error; err != nil {
+		return employee, err
+	}
+	visible, err := utils.CanSeeEmployee(c, db, employee.ID)
+	if err == nil && !visible {
+		err = gorm.ErrRecordNotFound
+	}
+	return employee, err
+}
+
+// employeeSortColumns are the fields the employee list can be sorted by.
+var employeeSortColumns = map[string]string{
+	"fullname":   "fullname",
+	"username":   "username",
+	"email":      "email",
+	"location":   "location",
+	"status":     "status",
+	"hire_date":  "hire_date",
+	"created_at": "created_at",
+	"id":         "id",
+}
+
+// employeeFilters applies the search text and the filters of the employee
+// list. Each filter takes a comma separated list of values.
+func employeeFilters(c echo.Context, query *gorm.DB) (*gorm.DB, error) {
+	search := c.QueryParam("q")
+	if search == "" {
+		// the search endpoint has always called it query
+		search = c.QueryParam("query")
+	}
+	columns := []string{"fullname", "username", "email"}
+	// matching on a phone number would reveal it to callers who cannot see it
+	if utils.HasPermission(c, models.PermEmployeesPersonal) {
+		columns = append(columns, "phone_number")
+	}
+	condition := "(" + strings.Join(columns, " LIKE ? OR ") + " LIKE ?)"
+	for _, term := range strings.Fields(search) {
+		pattern := utils.LikePattern(term)
+		args := make([]interface{}, len(columns))
+		for i := range args {
+			args[i] = pattern
+		}
+		query = query.Where(condition, args...)
+	}
+
+	if roles := queryList(c, "role"); len(roles) > 0 {
+		assigned := query.Session(&gorm.Session{NewDB: true}).Table("employee_roles").
+			Select("employee_roles.employee_id").
+			Joins("JOIN roles ON roles.id = employee_roles.role_id").
+			Where("roles.name IN ?", roles)
+		query = query.Where("id IN (?)", assigned)
+	}
+	if departments := queryList(c, "department"); len(departments) > 0 {
+		ids := make([]uint, 0, len(departments))
+		for _, department := range departments {
+			id, err := strconv.ParseUint(department, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid department %s", department)
+			}
+			ids = append(ids, uint(id))
+		}
+		query = query.Where("department_id IN ?", ids)
+	}
+	if locations := queryList(c, "location"); len(locations) > 0 {
+		query = query.Where("location IN ?", locations)
+	}
+	return employeeStatusFilter(c, query)
+}
+
+// queryList splits a comma separated query parameter, dropping empty items.
+func queryList(c echo.Context, name string) []string {
+	var items []string
+	for _, item := range strings.Split(c.QueryParam(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// employeeStatusFilter narrows query to the statuses named by the status
+// query parameter, a comma separated list or "all". Only active employees
+// are listed when it is missing.
+func employeeStatusFilter(c echo.Context, query *gorm.DB) (*gorm.DB, error) {
+	switch param := c.QueryParam("status"); param {
+	case "":
+		return query.Where("status = ?", models.EmployeeActive), nil
+	case "all":
+		return query, nil
+	default:
+		statuses := queryList(c, "status")
+		for _, status := range statuses {
+			if !validEmployeeStatus(status) {
+				return nil, fmt.Errorf("Unknown status %s", status)
+			}
+		}
+		return query.Where("status IN ?", statuses), nil
+	}
+}
+
+func validEmployeeStatus(status string) bool {
+	for _, known := range models.EmployeeStatuses {
+		if status == known {
+			return true
+		}
+	}
+	return false
 }
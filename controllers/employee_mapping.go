@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"time"
+
+	"attendance/models"
+	"attendance/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+// employeeResponse is the caller's view of an employee. Personal details
+// are only filled in when utils.CanSeePersonalDetails allows it.
+func employeeResponse(c echo.Context, employee models.Employee) models.EmployeeResponse {
+	response := models.EmployeeResponse{
+		ID:              employee.ID,
+		Username:        employee.Username,
+		Fullname:        employee.Fullname,
+		Email:           employee.Email,
+		Role:            employee.Role,
+		Location:        employee.Location,
+		DepartmentID:    employee.DepartmentID,
+		TeamID:          employee.TeamID,
+		ManagerID:       employee.ManagerID,
+		Status:          employee.Status,
+		HireDate:        dateString(employee.HireDate),
+		TerminationDate: dateString(employee.TerminationDate),
+		Version:         employee.Version,
+		CreatedAt:       employee.CreatedAt,
+		UpdatedAt:       employee.UpdatedAt,
+	}
+	if utils.CanSeePersonalDetails(c, employee.ID) {
+		response.PhoneNumber = employee.PhoneNumber
+		response.Address = employee.Address
+	}
+	return response
+}
+
+func employeeResponses(c echo.Context, employees []models.Employee) []models.EmployeeResponse {
+	responses := make([]models.EmployeeResponse, len(employees))
+	for i, employee := range employees {
+		responses[i] = employeeResponse(c, employee)
+	}
+	return responses
+}
+
+// newEmployee is the active employee described by a create request; hash is
+// the bcrypt hash of the requested password.
+func newEmployee(request models.CreateEmployeeRequest, hash string) (models.Employee, error) {
+	employee := models.Employee{
+		Username:    request.Username,
+		Fullname:    request.Fullname,
+		Password:    hash,
+		Email:       request.Email,
+		PhoneNumber: request.PhoneNumber,
+		Address:     request.Address,
+		Location:    request.Location,
+		Role:        "user",
+		Status:      models.EmployeeActive,
+	}
+	if request.HireDate != "" {
+		date, err := utils.ParseDate(request.HireDate)
+		if err != nil {
+			return employee, err
+		}
+		employee.HireDate = &date
+	}
+	return employee, nil
+}
+
+// updateEmployeeRequest holds the current values of an employee, so binding
+// a body onto it leaves omitted fields as they are.
+func updateEmployeeRequest(employee models.Employee) models.UpdateEmployeeRequest {
+	request := models.UpdateEmployeeRequest{
+		Username:    employee.Username,
+		Fullname:    employee.Fullname,
+		Email:       employee.Email,
+		PhoneNumber: employee.PhoneNumber,
+		Address:     employee.Address,
+		Location:    employee.Location,
+	}
+	if hireDate := dateString(employee.HireDate); hireDate != nil {
+		request.HireDate = *hireDate
+	}
+	return request
+}
+
+// applyEmployeeUpdate copies an update request onto the employee. An empty
+// hire date clears it.
+func applyEmployeeUpdate(employee *models.Employee, request models.UpdateEmployeeRequest) error {
+	employee.Username = request.Username
+	employee.Fullname = request.Fullname
+	employee.Email = request.Email
+	employee.PhoneNumber = request.PhoneNumber
+	employee.Address = request.Address
+	employee.Location = request.Location
+	employee.HireDate = nil
+	if request.HireDate != "" {
+		date, err := utils.ParseDate(request.HireDate)
+		if err != nil {
+			return err
+		}
+		employee.HireDate = &date
+	}
+	return nil
+}
+
+func profileResponse(employee models.Employee, roles []string) models.ProfileResponse {
+	return models.ProfileResponse{
+		ID:           employee.ID,
+		Username:     employee.Username,
+		Fullname:     employee.Fullname,
+		Email:        employee.Email,
+		Role:         employee.Role,
+		Roles:        roles,
+		PhoneNumber:  employee.PhoneNumber,
+		Address:      employee.Address,
+		Location:     employee.Location,
+		DepartmentID: employee.DepartmentID,
+		TeamID:       employee.TeamID,
+		ManagerID:    employee.ManagerID,
+		Reminders:    !employee.RemindersOptOut,
+		CreatedAt:    employee.CreatedAt,
+	}
+}
+
+func employeeEventData(employee models.Employee) models.EmployeeEventData {
+	return models.EmployeeEventData{
+		ID:              employee.ID,
+		Username:        employee.Username,
+		Fullname:        employee.Fullname,
+		Email:           employee.Email,
+		Status:          employee.Status,
+		TerminationDate: employee.TerminationDate,
+	}
+}
+
+// dateString formats a date column as YYYY-MM-DD.
+func dateString(date *time.Time) *string {
+	if date == nil {
+		return nil
+	}
+	formatted := date.Format(utils.DateLayout)
+	return &formatted
+}
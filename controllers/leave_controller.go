@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type LeaveController struct{}
+
+// RequestLeave godoc
+// @Summary Request leave
+// @Description Submit a leave request for the logged in employee
+// @Tags Leave
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param leave body models.LeaveRequest true "Leave request"
+// @Success 200 {object} models.Leave
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /leaves [post]
+func (lc *LeaveController) RequestLeave(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	var request models.LeaveRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+	if !validLeaveType(request.Type) {
+		return apperror.BadRequest("Invalid leave type")
+	}
+	start, err := utils.ParseDate(request.StartDate)
+	if err != nil {
+		return apperror.BadRequest("Invalid start_date")
+	}
+	end, err := utils.ParseDate(request.EndDate)
+	if err != nil || end.Before(start) {
+		return apperror.BadRequest("Invalid end_date")
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	leave := models.Leave{
+		EmployeeID: employeeID,
+		Type:       request.Type,
+		StartDate:  start,
+		EndDate:    end,
+		Reason:     request.Reason,
+		Status:     models.LeavePending,
+	}
+	if err := db.Create(&leave).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	utils.SetETag(c, leave.Version)
+	return c.JSON(http.StatusOK, leave)
+}
+
+// GetLeaves godoc
+// @Summary List leave requests
+// @Description Leave approvers see the requests of their reporting subtree, other employees only their own
+// @Tags Leave
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param status query string false "Filter by status (pending, approved, rejected)"
+// @Success 200 {array} models.Leave
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /leaves [get]
+func (lc *LeaveController) GetLeaves(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	query := db.Order("start_date DESC")
+	if utils.HasPermission(c, models.PermLeaveApprove) {
+		if query, err = utils.VisibleTo(c, query, "employee_id"); err != nil {
+			return apperror.Internal(err)
+		}
+	} else {
+		query = query.Where("employee_id = ?", employeeID)
+	}
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var leaves []models.Leave
+	if err := query.Find(&leaves).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, leaves)
+}
+
+// ApproveLeave godoc
+// @Summary Approve a leave request
+// @Tags Leave
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Leave ID"
+// @Param If-Match header string true "Version of the leave request in double quotes, as its ETag"
+// @Success 200 {object} models.Leave
+// @Header 200 {string} ETag "Version of the leave request, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /leaves/{id}/approve [put]
+func (lc *LeaveController) ApproveLeave(c echo.Context) error {
+	return lc.reviewLeave(c, models.LeaveApproved)
+}
+
+// RejectLeave godoc
+// @Summary Reject a leave request
+// @Tags Leave
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Leave ID"
+// @Param If-Match header string true "Version of the leave request in double quotes, as its ETag"
+// @Success 200 {object} models.Leave
+// @Header 200 {string} ETag "Version of the leave request, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /leaves/{id}/reject [put]
+func (lc *LeaveController) RejectLeave(c echo.Context) error {
+	return lc.reviewLeave(c, models.LeaveRejected)
+}
+
+func (lc *LeaveController) reviewLeave(c echo.Context, status string) error {
+	reviewerID := utils.CallerID(c)
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var leave models.Leave
+	if err := db.First(&leave, c.Param("id")).Error; err != nil {
+		return apperror.ErrLeaveNotFound
+	}
+	if visible, err := utils.CanSeeEmployee(c, db, uint(leave.EmployeeID)); err != nil || !visible {
+		return apperror.ErrLeaveNotFound
+	}
+	if leave.EmployeeID == reviewerID {
+		return apperror.ErrLeaveOwnReview
+	}
+	if leave.Status != models.LeavePending {
+		return apperror.ErrLeaveReviewed
+	}
+	if err := utils.CheckIfMatch(c, leave.Version); err != nil {
+		return err
+	}
+
+	leave.Status = status
+	leave.ReviewedByID = &reviewerID
+	if err := utils.SaveVersioned(db, &leave, &leave.Version, "status", "reviewed_by_id"); err != nil {
+		return apperror.From(err)
+	}
+
+	event := models.EventLeaveApproved
+	if status == models.LeaveRejected {
+		event = models.EventLeaveRejected
+	}
+	utils.PublishEvent(db, event, leave)
+
+	utils.SetETag(c, leave.Version)
+	return c.JSON(http.StatusOK, leave)
+}
+
+func validLeaveType(leaveType string) bool {
+	for _, t := range models.LeaveTypes {
+		if t == leaveType {
+			return true
+		}
+	}
+	return false
+}
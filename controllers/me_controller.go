@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+// MeController serves the logged in employee's own data.
+type MeController struct{}
+
+// GetProfile godoc
+// @Summary Get my profile
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} models.ProfileResponse
+// @Header 200 {string} ETag "Version of the caller's employee record, for If-Match"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me [get]
+func (mc *MeController) GetProfile(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var employee models.Employee
+	if err := db.First(&employee, employeeID).Error; err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	roles, err := utils.RoleNames(db, employee.ID)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, profileResponse(employee, roles))
+}
+
+// UpdateProfile godoc
+// @Summary Update my profile
+// @Description Update the profile fields employees may change themselves: phone number and address
+// @Tags Me
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param If-Match header string true "ETag from GET /me"
+// @Param profile body models.ProfileUpdateRequest true "Profile fields"
+// @Success 200 {object} models.ProfileResponse
+// @Header 200 {string} ETag "Version of the caller's employee record, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me [patch]
+func (mc *MeController) UpdateProfile(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	var request models.ProfileUpdateRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var employee models.Employee
+	if err := db.First(&employee, employeeID).Error; err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := utils.CheckIfMatch(c, employee.Version); err != nil {
+		return err
+	}
+
+	if request.PhoneNumber != nil {
+		employee.PhoneNumber = *request.PhoneNumber
+	}
+	if request.Address != nil {
+		employee.Address = *request.Address
+	}
+	if err := utils.SaveEmployee(db, &employee, "phone_number", "address"); err != nil {
+		return apperror.From(err)
+	}
+
+	roles, err := utils.RoleNames(db, employee.ID)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, profileResponse(employee, roles))
+}
+
+// ChangePassword godoc
+// @Summary Change my password
+// @Description Change the caller's password after verifying the current one. Every session, including the current one, is logged out.
+// @Tags Me
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param password body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/password [put]
+func (mc *MeController) ChangePassword(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	var request models.ChangePasswordRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var employee models.Employee
+	if err := db.First(&employee, employeeID).Error; err != nil {
+		return apperror.ErrEmployeeNotFound.WithStatus(http.StatusUnauthorized)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(employee.Password), []byte(request.CurrentPassword)); err != nil {
+		return apperror.ErrWrongPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&employee).Updates(map[string]interface{}{
+			"password":            string(hash),
+			"password_changed_at": time.Now(),
+			"version":             gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		return utils.RevokeSessions(tx, employee.ID)
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Password changed successfully, please log in again"})
+}
+
+// GetAttendance godoc
+// @Summary List my attendance sessions
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Number of items per page, at most 200" default(50)
+// @Success 200 {object} models.AttendanceSessionList
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/attendance [get]
+func (mc *MeController) GetAttendance(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+	after, limit, err := utils.ParseCursor(c, "me-attendance")
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	sessions, page, err := attendancePage(utils.SessionQuery(db).Where("clock_ins.employee_id = ?", employeeID), "me-attendance", after, limit)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.AttendanceSessionList{Data: sessions, CursorPagination: page})
+}
+
+// GetStatus godoc
+// @Summary Get my clock status
+// @Description Tells whether the caller is clocked in and returns today's schedule
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} models.ClockStatusResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/status [get]
+func (mc *MeController) GetStatus(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	session, err := utils.OpenSession(db, employeeID)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	today, err := utils.TodaySchedule(db, employeeID, time.Now())
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.ClockStatusResponse{
+		ClockedIn:   session != nil,
+		OpenSession: session,
+		Today:       today,
+	})
+}
+
+// UpdateReminders godoc
+// @Summary Turn my clock reminders on or off
+// @Description Opt in to or out of the scheduled clock-in and clock-out reminder emails
+// @Tags Me
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param If-Match header string true "ETag from GET /me"
+// @Param preference body models.ReminderPreferenceRequest true "Reminder preference"
+// @Success 200 {object} models.ProfileResponse
+// @Header 200 {string} ETag "Version of the caller's employee record, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/reminders [put]
+func (mc *MeController) UpdateReminders(c echo.Context) error {
+	employeeID := utils.CallerID(c)
+
+	var request models.ReminderPreferenceRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var employee models.Employee
+	if err := db.First(&employee, employeeID).Error; err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := utils.CheckIfMatch(c, employee.Version); err != nil {
+		return err
+	}
+	employee.RemindersOptOut = !request.Enabled
+	if err := utils.SaveEmployee(db, &employee, "reminders_opt_out"); err != nil {
+		return apperror.From(err)
+	}
+
+	roles, err := utils.RoleNames(db, employee.ID)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, profileResponse(employee, roles))
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type NotificationController struct{}
+
+// GetNotifications godoc
+// @Summary List queued and delivered notifications
+// @Description List outbox entries with their delivery status, newest first
+// @Tags Notifications
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param status query string false "Filter by status (pending, sending, sent, failed)"
+// @Param event query string false "Filter by event"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Number of items per page, at most 200" default(50)
+// @Success 200 {object} models.NotificationList
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /notifications [get]
+func (nc *NotificationController) GetNotifications(c echo.Context) error {
+	after, limit, err := utils.ParseCursor(c, "notifications")
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	query := db.Model(&models.Notification{})
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.QueryParam("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	notifications := []models.Notification{}
+	if err := utils.CursorQuery(query, "created_at", "id", after, limit).Find(&notifications).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	n, page := utils.CursorPage("notifications", limit, len(notifications), func(i int) utils.Cursor {
+		return utils.Cursor{Time: notifications[i].CreatedAt, ID: notifications[i].ID}
+	})
+
+	return c.JSON(http.StatusOK, models.NotificationList{Data: notifications[:n], CursorPagination: page})
+}
+
+// RetryNotification godoc
+// @Summary Retry a notification
+// @Description Put a failed notification back in the outbox queue
+// @Tags Notifications
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Notification ID"
+// @Success 200 {object} models.Notification
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /notifications/{id}/retry [post]
+func (nc *NotificationController) RetryNotification(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var notification models.Notification
+	if err := db.First(&notification, c.Param("id")).Error; err != nil {
+		return apperror.ErrNotificationNotFound
+	}
+	if notification.Status != models.NotificationFailed {
+		return apperror.ErrNotificationNotRetry
+	}
+	if err := utils.RetryNotification(db, &notification); err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, notification)
+}
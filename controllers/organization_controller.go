@@ -0,0 +1,467 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type OrganizationController struct{}
+
+// GetDepartments godoc
+// @Summary List departments
+// @Description List departments as a tree with their nested teams
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.DepartmentNode
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /departments [get]
+func (oc *OrganizationController) GetDepartments(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	tree, err := utils.BuildDepartmentTree(db)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, tree)
+}
+
+// CreateDepartment godoc
+// @Summary Create a department
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param department body models.DepartmentRequest true "Department"
+// @Success 200 {object} models.Department
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /departments [post]
+func (oc *OrganizationController) CreateDepartment(c echo.Context) error {
+	return oc.saveDepartment(c, false)
+}
+
+// UpdateDepartment godoc
+// @Summary Rename or move a department
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Department ID"
+// @Param department body models.DepartmentRequest true "Department"
+// @Param If-Match header string true "Version of the department in double quotes, as its ETag"
+// @Success 200 {object} models.Department
+// @Header 200 {string} ETag "Version of the department, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /departments/{id} [put]
+func (oc *OrganizationController) UpdateDepartment(c echo.Context) error {
+	return oc.saveDepartment(c, true)
+}
+
+func (oc *OrganizationController) saveDepartment(c echo.Context, existing bool) error {
+	var request models.DepartmentRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var department models.Department
+	if existing {
+		if err := db.First(&department, c.Param("id")).Error; err != nil {
+			return apperror.ErrDepartmentNotFound
+		}
+		if err := utils.CheckIfMatch(c, department.Version); err != nil {
+			return err
+		}
+	}
+	if err := utils.ValidateDepartmentParent(db, department.ID, request.ParentID); err != nil {
+		return apperror.Invalid(err)
+	}
+
+	department.Name = request.Name
+	department.ParentID = request.ParentID
+	if existing {
+		err = utils.SaveVersioned(db, &department, &department.Version, "name", "parent_id")
+	} else {
+		err = db.Create(&department).Error
+	}
+	if err != nil {
+		return apperror.From(err)
+	}
+
+	utils.SetETag(c, department.Version)
+	return c.JSON(http.StatusOK, department)
+}
+
+// DeleteDepartment godoc
+// @Summary Delete an empty department
+// @Description Departments that still have sub-departments, teams or employees cannot be deleted
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Department ID"
+// @Param If-Match header string true "Version of the department in double quotes, as its ETag"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /departments/{id} [delete]
+func (oc *OrganizationController) DeleteDepartment(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var department models.Department
+	if err := db.First(&department, c.Param("id")).Error; err != nil {
+		return apperror.ErrDepartmentNotFound
+	}
+	if err := utils.CheckIfMatch(c, department.Version); err != nil {
+		return err
+	}
+
+	var children, teams, employees int64
+	db.Model(&models.Department{}).Where("parent_id = ?", department.ID).Count(&children)
+	db.Model(&models.Team{}).Where("department_id = ?", department.ID).Count(&teams)
+	db.Model(&models.Employee{}).Where("department_id = ?", department.ID).Count(&employees)
+	if children+teams+employees > 0 {
+		return apperror.ErrDepartmentNotEmpty
+	}
+
+	if err := utils.DeleteVersioned(db, &department, department.Version, nil); err != nil {
+		return apperror.From(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Department Deleted Succesfully"})
+}
+
+// CreateTeam godoc
+// @Summary Create a team
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param team body models.TeamRequest true "Team"
+// @Success 200 {object} models.Team
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /teams [post]
+func (oc *OrganizationController) CreateTeam(c echo.Context) error {
+	return oc.saveTeam(c, false)
+}
+
+// UpdateTeam godoc
+// @Summary Rename or move a team
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Team ID"
+// @Param team body models.TeamRequest true "Team"
+// @Param If-Match header string true "Version of the team in double quotes, as its ETag"
+// @Success 200 {object} models.Team
+// @Header 200 {string} ETag "Version of the team, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /teams/{id} [put]
+func (oc *OrganizationController) UpdateTeam(c echo.Context) error {
+	return oc.saveTeam(c, true)
+}
+
+func (oc *OrganizationController) saveTeam(c echo.Context, existing bool) error {
+	var request models.TeamRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var team models.Team
+	if existing {
+		if err := db.First(&team, c.Param("id")).Error; err != nil {
+			return apperror.ErrTeamNotFound
+		}
+		if err := utils.CheckIfMatch(c, team.Version); err != nil {
+			return err
+		}
+	}
+
+	team.Name = request.Name
+	team.DepartmentID = request.DepartmentID
+	team.ParentID = request.ParentID
+	if err := utils.ValidateTeamParent(db, team); err != nil {
+		return apperror.Invalid(err)
+	}
+	if existing {
+		err = utils.SaveVersioned(db, &team, &team.Version, "name", "department_id", "parent_id")
+	} else {
+		err = db.Create(&team).Error
+	}
+	if err != nil {
+		return apperror.From(err)
+	}
+
+	utils.SetETag(c, team.Version)
+	return c.JSON(http.StatusOK, team)
+}
+
+// DeleteTeam godoc
+// @Summary Delete an empty team
+// @Description Teams that still have sub-teams or members cannot be deleted
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Team ID"
+// @Param If-Match header string true "Version of the team in double quotes, as its ETag"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /teams/{id} [delete]
+func (oc *OrganizationController) DeleteTeam(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var team models.Team
+	if err := db.First(&team, c.Param("id")).Error; err != nil {
+		return apperror.ErrTeamNotFound
+	}
+	if err := utils.CheckIfMatch(c, team.Version); err != nil {
+		return err
+	}
+
+	var children, members int64
+	db.Model(&models.Team{}).Where("parent_id = ?", team.ID).Count(&children)
+	db.Model(&models.Employee{}).Where("team_id = ?", team.ID).Count(&members)
+	if children+members > 0 {
+		return apperror.ErrTeamNotEmpty
+	}
+
+	if err := utils.DeleteVersioned(db, &team, team.Version, nil); err != nil {
+		return apperror.From(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Team Deleted Succesfully"})
+}
+
+// UpdatePlacement godoc
+// @Summary Place an employee in the organisation
+// @Description Set an employee's department, team and manager
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Param If-Match header string true "ETag from GET /employees/{id}"
+// @Param placement body models.PlacementRequest true "Placement"
+// @Success 200 {object} models.EmployeeResponse
+// @Header 200 {string} ETag "Version of the employee, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/organization [put]
+func (oc *OrganizationController) UpdatePlacement(c echo.Context) error {
+	var request models.PlacementRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := utils.CheckIfMatch(c, employee.Version); err != nil {
+		return err
+	}
+
+	if request.DepartmentID != nil {
+		if err := db.First(&models.Department{}, *request.DepartmentID).Error; err != nil {
+			return apperror.ErrDepartmentNotFound.WithStatus(http.StatusBadRequest)
+		}
+	}
+	if request.TeamID != nil {
+		var team models.Team
+		if err := db.First(&team, *request.TeamID).Error; err != nil {
+			return apperror.ErrTeamNotFound.WithStatus(http.StatusBadRequest)
+		}
+		if request.DepartmentID == nil || team.DepartmentID != *request.DepartmentID {
+			return apperror.BadRequest("Team does not belong to the department")
+		}
+	}
+	if err := utils.ValidateManager(db, employee.ID, request.ManagerID); err != nil {
+		return apperror.Invalid(err)
+	}
+
+	employee.DepartmentID = request.DepartmentID
+	employee.TeamID = request.TeamID
+	employee.ManagerID = request.ManagerID
+	if err := utils.SaveEmployee(db, &employee, "department_id", "team_id", "manager_id"); err != nil {
+		return apperror.From(err)
+	}
+
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, employeeResponse(c, employee))
+}
+
+// GetReports godoc
+// @Summary List an employee's reports
+// @Description List the direct reports of an employee, or the whole reporting subtree with indirect=true
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Param indirect query bool false "Include indirect reports"
+// @Param status query string false "Comma separated statuses (active, suspended, terminated) or all; defaults to active"
+// @Success 200 {array} models.EmployeeResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/reports [get]
+func (oc *OrganizationController) GetReports(c echo.Context) error {
+	callerID := utils.CallerID(c)
+	managerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid employee ID")
+	}
+	if callerID != managerID && !utils.HasPermission(c, models.PermEmployeesRead) {
+		return apperror.ErrForbidden
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if visible, err := utils.CanSeeEmployee(c, db, uint(managerID)); err != nil || !visible {
+		return apperror.ErrEmployeeNotFound
+	}
+
+	var employees []models.Employee
+	query, err := employeeStatusFilter(c, db.Order("fullname"))
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+	if indirect, _ := strconv.ParseBool(c.QueryParam("indirect")); indirect {
+		ids, err := utils.ReportingSubtree(db, uint(managerID))
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if len(ids) == 0 {
+			return c.JSON(http.StatusOK, employeeResponses(c, employees))
+		}
+		query = query.Where("id IN ?", ids)
+	} else {
+		query = query.Where("manager_id = ?", managerID)
+	}
+	if err := query.Find(&employees).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, employeeResponses(c, employees))
+}
+
+// GetOrgChart godoc
+// @Summary Get the org chart
+// @Description Get the reporting tree of the whole organisation, or below one employee with root
+// @Tags Organization
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param root query int false "Employee ID to start the tree from"
+// @Param status query string false "Comma separated statuses (active, suspended, terminated) or all; defaults to active"
+// @Success 200 {array} models.OrgChartNode
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /org-chart [get]
+func (oc *OrganizationController) GetOrgChart(c echo.Context) error {
+	var root *uint
+	if param := c.QueryParam("root"); param != "" {
+		id, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return apperror.BadRequest("Invalid root")
+		}
+		rootID := uint(id)
+		root = &rootID
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	query, err := employeeStatusFilter(c, db)
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+
+	chart, err := utils.BuildOrgChart(query, root)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, chart)
+}
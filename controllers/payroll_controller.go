@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type PayrollController struct{}
+
+// CreatePayPeriod godoc
+// @Summary Create a pay period
+// @Description Create an open pay period covering a date range
+// @Tags Payroll
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param period body models.PayPeriodRequest true "Pay period"
+// @Success 200 {object} models.PayPeriod
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /payroll/periods [post]
+func (pc *PayrollController) CreatePayPeriod(c echo.Context) error {
+	var request models.PayPeriodRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	start, err := utils.ParseDate(request.StartDate)
+	if err != nil {
+		return apperror.BadRequest("Invalid start_date")
+	}
+	end, err := utils.ParseDate(request.EndDate)
+	if err != nil || end.Before(start) {
+		return apperror.BadRequest("Invalid end_date")
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	period := models.PayPeriod{Name: request.Name, StartDate: start, EndDate: end, Status: models.PayPeriodOpen}
+	if err := db.Create(&period).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, period)
+}
+
+// GetPayPeriods godoc
+// @Summary List pay periods
+// @Description List all pay periods, newest first
+// @Tags Payroll
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.PayPeriod
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /payroll/periods [get]
+func (pc *PayrollController) GetPayPeriods(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var periods []models.PayPeriod
+	if err := db.Order("start_date DESC").Find(&periods).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, periods)
+}
+
+// ClosePayPeriod godoc
+// @Summary Close a pay period
+// @Description Freeze the payroll figures of a pay period so that exports are reproducible
+// @Tags Payroll
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Pay period ID"
+// @Success 200 {object} models.PayPeriod
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /payroll/periods/{id}/close [post]
+func (pc *PayrollController) ClosePayPeriod(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var period models.PayPeriod
+	if err := db.First(&period, c.Param("id")).Error; err != nil {
+		return apperror.ErrPayPeriodNotFound
+	}
+	if period.Status == models.PayPeriodClosed {
+		return apperror.ErrPayPeriodClosed
+	}
+	if err := utils.ClosePayPeriod(db, &period); err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, period)
+}
+
+// ExportPayroll godoc
+// @Summary Export payroll for a pay period
+// @Description Export one row per employee with regular hours, overtime by rate, leave by type, absences and late minutes
+// @Tags Payroll
+// @Security ApiKeyAuth
+// @Produce json
+// @Produce text/csv
+// @Produce text/plain
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Pay period ID"
+// @Param format query string false "csv, json or fixed" default(csv)
+// @Param layout query string false "Named column layout (default, summary)" default(default)
+// @Param columns query string false "Comma separated column list, overrides layout"
+// @Success 200 {string} string "Payroll export"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /payroll/periods/{id}/export [get]
+func (pc *PayrollController) ExportPayroll(c echo.Context) error {
+	format := c.QueryParam("format")
+	columns, err := utils.ResolvePayrollColumns(c.QueryParam("layout"), c.QueryParam("columns"))
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var period models.PayPeriod
+	if err := db.First(&period, c.Param("id")).Error; err != nil {
+		return apperror.ErrPayPeriodNotFound
+	}
+
+	rows, err := utils.PayrollRows(db, period)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var out bytes.Buffer
+	if err := utils.WritePayroll(&out, format, columns, rows); err != nil {
+		return apperror.Invalid(err)
+	}
+
+	extension := map[string]string{"": "csv", "csv": "csv", "json": "json", "fixed": "txt"}[format]
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="payroll-%d.%s"`, period.ID, extension))
+	return c.Blob(http.StatusOK, utils.PayrollContentType(format), out.Bytes())
+}
@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type RoleController struct{}
+
+// GetPermissions godoc
+// @Summary List permissions
+// @Description List every permission that can be granted to a role
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.Permission
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /permissions [get]
+func (rc *RoleController) GetPermissions(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var permissions []models.Permission
+	if err := db.Order("name").Find(&permissions).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, permissions)
+}
+
+// GetRoles godoc
+// @Summary List roles
+// @Description List every role with its permissions
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.Role
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /roles [get]
+func (rc *RoleController) GetRoles(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var roles []models.Role
+	if err := db.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param role body models.RoleRequest true "Role"
+// @Success 200 {object} models.Role
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /roles [post]
+func (rc *RoleController) CreateRole(c echo.Context) error {
+	return rc.saveRole(c, false)
+}
+
+// UpdateRole godoc
+// @Summary Update a custom role
+// @Description Replace the name, description and permissions of a role. Built-in roles cannot be changed.
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Role ID"
+// @Param role body models.RoleRequest true "Role"
+// @Param If-Match header string true "Version of the role in double quotes, as its ETag"
+// @Success 200 {object} models.Role
+// @Header 200 {string} ETag "Version of the role, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /roles/{id} [put]
+func (rc *RoleController) UpdateRole(c echo.Context) error {
+	return rc.saveRole(c, true)
+}
+
+func (rc *RoleController) saveRole(c echo.Context, existing bool) error {
+	var request models.RoleRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var role models.Role
+	if existing {
+		if err := db.First(&role, c.Param("id")).Error; err != nil {
+			return apperror.ErrRoleNotFound
+		}
+		if role.BuiltIn {
+			return apperror.ErrRoleBuiltIn
+		}
+		if err := utils.CheckIfMatch(c, role.Version); err != nil {
+			return err
+		}
+	}
+	if _, builtIn := models.BuiltInRoles[request.Name]; builtIn && role.Name != request.Name {
+		return apperror.ErrRoleNameReserved
+	}
+
+	var permissions []models.Permission
+	if len(request.Permissions) > 0 {
+		if err := db.Where("name IN ?", request.Permissions).Find(&permissions).Error; err != nil {
+			return apperror.Internal(err)
+		}
+	}
+	if len(permissions) != len(request.Permissions) {
+		return apperror.ErrUnknownPermission
+	}
+
+	role.Name = request.Name
+	role.Description = request.Description
+	role.RequireTwoFactor = request.RequireTwoFactor
+	if existing {
+		err = utils.SaveVersioned(db, &role, &role.Version, "name", "description", "require_two_factor")
+	} else {
+		err = db.Omit("Permissions").Create(&role).Error
+	}
+	if errors.Is(err, apperror.ErrVersionConflict) {
+		return err
+	}
+	if err != nil {
+		return apperror.ErrRoleNameTaken
+	}
+	if err := db.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+		return apperror.Internal(err)
+	}
+
+	utils.SetETag(c, role.Version)
+	return c.JSON(http.StatusOK, role)
+}
+
+// UpdateRoleTwoFactor godoc
+// @Summary Require 2FA for a role
+// @Description Turn the two-factor requirement of a role on or off. Works for built-in roles too. Members without 2FA enrol at their next login.
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Role ID"
+// @Param requirement body models.TwoFactorRoleRequest true "Requirement"
+// @Param If-Match header string true "Version of the role in double quotes, as its ETag"
+// @Success 200 {object} models.Role
+// @Header 200 {string} ETag "Version of the role, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /roles/{id}/two-factor [put]
+func (rc *RoleController) UpdateRoleTwoFactor(c echo.Context) error {
+	var request models.TwoFactorRoleRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var role models.Role
+	if err := db.Preload("Permissions").First(&role, c.Param("id")).Error; err != nil {
+		return apperror.ErrRoleNotFound
+	}
+	if err := utils.CheckIfMatch(c, role.Version); err != nil {
+		return err
+	}
+
+	role.RequireTwoFactor = request.Required
+	if err := utils.SaveVersioned(db, &role, &role.Version, "require_two_factor"); err != nil {
+		return apperror.From(err)
+	}
+
+	utils.SetETag(c, role.Version)
+	return c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole godoc
+// @Summary Delete a custom role
+// @Description Delete a role and remove it from every employee. Built-in roles cannot be deleted.
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Role ID"
+// @Param If-Match header string true "Version of the role in double quotes, as its ETag"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /roles/{id} [delete]
+func (rc *RoleController) DeleteRole(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var role models.Role
+	if err := db.First(&role, c.Param("id")).Error; err != nil {
+		return apperror.ErrRoleNotFound
+	}
+	if role.BuiltIn {
+		return apperror.ErrRoleBuiltIn.WithMessage("Built-in roles cannot be deleted")
+	}
+	if err := utils.CheckIfMatch(c, role.Version); err != nil {
+		return err
+	}
+
+	err = utils.DeleteVersioned(db, &role, role.Version, func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM employee_roles WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&role).Association("Permissions").Clear()
+	})
+	if err != nil {
+		return apperror.From(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Role Deleted Succesfully"})
+}
+
+// GetEmployeeRoles godoc
+// @Summary List an employee's roles
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Success 200 {array} models.Role
+// @Header 200 {string} ETag "Version of the employee, for If-Match"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/roles [get]
+func (rc *RoleController) GetEmployeeRoles(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := db.Preload("Roles.Permissions").First(&employee, employee.ID).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, employee.Roles)
+}
+
+// UpdateEmployeeRoles godoc
+// @Summary Assign roles to an employee
+// @Description Replace the roles assigned to an employee
+// @Tags Roles
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Param If-Match header string true "ETag from GET /employees/{id}"
+// @Param roles body models.RoleAssignmentRequest true "Role names"
+// @Success 200 {array} string
+// @Header 200 {string} ETag "Version of the employee, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/roles [put]
+func (rc *RoleController) UpdateEmployeeRoles(c echo.Context) error {
+	var request models.RoleAssignmentRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+	if err := utils.CheckIfMatch(c, employee.Version); err != nil {
+		return err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// the roles are part of the employee, so assigning them bumps its version
+		if err := utils.SaveEmployee(tx, &employee); err != nil {
+			return err
+		}
+		return utils.AssignRoles(tx, &employee, request.Roles)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.ErrUnknownRole
+	}
+	if err != nil {
+		return apperror.From(err)
+	}
+
+	sort.Strings(request.Roles)
+	utils.SetETag(c, employee.Version)
+	return c.JSON(http.StatusOK, request.Roles)
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type ScheduleController struct{}
+
+// GetSchedule godoc
+// @Summary Get an employee's work schedule
+// @Description Returns the default schedule when the employee has none
+// @Tags Schedule
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Success 200 {object} models.WorkSchedule
+// @Header 200 {string} ETag "Version of the schedule, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/schedule [get]
+func (sc *ScheduleController) GetSchedule(c echo.Context) error {
+	callerID := utils.CallerID(c)
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid employee ID")
+	}
+	if callerID != employeeID && !utils.HasPermission(c, models.PermEmployeesRead) {
+		return apperror.ErrForbidden
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if visible, err := utils.CanSeeEmployee(c, db, uint(employeeID)); err != nil || !visible {
+		return apperror.ErrEmployeeNotFound
+	}
+
+	schedule := utils.ScheduleFor(db, employeeID)
+	utils.SetETag(c, schedule.Version)
+	return c.JSON(http.StatusOK, schedule)
+}
+
+// UpdateSchedule godoc
+// @Summary Set an employee's work schedule
+// @Tags Schedule
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Param If-Match header string true "ETag from GET /employees/{id}/schedule"
+// @Param schedule body models.ScheduleRequest true "Schedule"
+// @Success 200 {object} models.WorkSchedule
+// @Header 200 {string} ETag "Version of the schedule, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/schedule [put]
+func (sc *ScheduleController) UpdateSchedule(c echo.Context) error {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apperror.BadRequest("Invalid employee ID")
+	}
+
+	var request models.ScheduleRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	if _, err := findVisibleEmployee(c, db); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.ErrEmployeeNotFound
+		}
+		return apperror.Internal(err)
+	}
+
+	var schedule models.WorkSchedule
+	err = db.Where("employee_id = ?", employeeID).First(&schedule).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.Internal(err)
+	}
+	// an employee without a schedule has the default one, at version 0
+	if err := utils.CheckIfMatch(c, schedule.Version); err != nil {
+		return err
+	}
+	schedule.EmployeeID = employeeID
+	schedule.StartTime = request.StartTime
+	schedule.EndTime = request.EndTime
+	schedule.WorkDays = request.WorkDays
+	if err := utils.ValidateSchedule(schedule); err != nil {
+		return apperror.Invalid(err)
+	}
+
+	if schedule.ID == 0 {
+		// start at 1 so the default schedule's tag no longer matches
+		schedule.Version = 1
+		err = db.Create(&schedule).Error
+		if utils.IsDuplicateKey(err) {
+			err = apperror.ErrVersionConflict
+		}
+	} else {
+		err = utils.SaveVersioned(db, &schedule, &schedule.Version, "start_time", "end_time", "work_days")
+	}
+	if err != nil {
+		return apperror.From(err)
+	}
+
+	utils.SetETag(c, schedule.Version)
+	return c.JSON(http.StatusOK, schedule)
+}
+
+// GetHolidays godoc
+// @Summary List holidays
+// @Tags Schedule
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.Holiday
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /holidays [get]
+func (sc *ScheduleController) GetHolidays(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var holidays []models.Holiday
+	if err := db.Order("date").Find(&holidays).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, holidays)
+}
+
+// CreateHoliday godoc
+// @Summary Add a holiday
+// @Description Holidays are treated as rest days by payroll
+// @Tags Schedule
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param holiday body models.HolidayRequest true "Holiday"
+// @Success 200 {object} models.Holiday
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /holidays [post]
+func (sc *ScheduleController) CreateHoliday(c echo.Context) error {
+	var request models.HolidayRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	date, err := utils.ParseDate(request.Date)
+	if err != nil {
+		return apperror.BadRequest("Invalid date")
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	holiday := models.Holiday{Date: date, Name: request.Name}
+	if err := db.Create(&holiday).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, holiday)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+// TwoFactorController manages TOTP two-factor authentication.
+type TwoFactorController struct{}
+
+// Enroll godoc
+// @Summary Start 2FA enrollment
+// @Description Generate a TOTP secret for the caller. Add it to an authenticator app, e.g. by turning the provisioning URI into a QR code, then confirm with a code.
+// @Tags Two-Factor
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} models.TwoFactorEnrollResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/2fa/enroll [post]
+func (tc *TwoFactorController) Enroll(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var employee models.Employee
+	if err := db.First(&employee, utils.CallerID(c)).Error; err != nil {
+		return apperror.ErrEmployeeNotFound.WithStatus(http.StatusUnauthorized)
+	}
+
+	enrollment, err := utils.BeginTwoFactorEnrollment(db, employee)
+	if errors.Is(err, utils.ErrTwoFactorEnabled) {
+		return apperror.ErrTwoFactorEnabled
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// Confirm godoc
+// @Summary Confirm 2FA enrollment
+// @Description Enable 2FA with a code from the authenticator app. The response holds the recovery codes, which are not shown again.
+// @Tags Two-Factor
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param code body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/2fa/confirm [post]
+func (tc *TwoFactorController) Confirm(c echo.Context) error {
+	var request models.TwoFactorCodeRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	codes, err := utils.ConfirmTwoFactorEnrollment(db, uint(utils.CallerID(c)), request.Code)
+	switch {
+	case errors.Is(err, utils.ErrTwoFactorEnabled):
+		return apperror.ErrTwoFactorEnabled
+	case errors.Is(err, utils.ErrTwoFactorNotEnrolled):
+		return apperror.ErrTwoFactorNotEnrolled
+	case errors.Is(err, utils.ErrInvalidTwoFactorCode):
+		return apperror.ErrInvalidTwoFactorCode
+	case err != nil:
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Replace my recovery codes
+// @Description Issue new recovery codes after verifying a current code. The old codes stop working.
+// @Tags Two-Factor
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param code body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/2fa/recovery-codes [post]
+func (tc *TwoFactorController) RegenerateRecoveryCodes(c echo.Context) error {
+	var request models.TwoFactorCodeRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employeeID := uint(utils.CallerID(c))
+	if err := utils.VerifySecondFactor(db, employeeID, request.Code); err != nil {
+		return apperror.ErrInvalidTwoFactorCode
+	}
+	codes, err := utils.RegenerateRecoveryCodes(db, employeeID)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable godoc
+// @Summary Turn off my 2FA
+// @Description Disable 2FA after verifying a current code. Not allowed when one of the caller's roles requires 2FA.
+// @Tags Two-Factor
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param code body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /me/2fa [delete]
+func (tc *TwoFactorController) Disable(c echo.Context) error {
+	var request models.TwoFactorCodeRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employeeID := uint(utils.CallerID(c))
+	required, err := utils.TwoFactorRequired(db, employeeID)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if required {
+		return apperror.ErrTwoFactorRequired
+	}
+	if err := utils.VerifySecondFactor(db, employeeID, request.Code); err != nil {
+		return apperror.ErrInvalidTwoFactorCode
+	}
+	if err := utils.DisableTwoFactor(db, employeeID); err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Two-factor authentication disabled"})
+}
+
+// Reset godoc
+// @Summary Reset an employee's 2FA
+// @Description Remove the employee's TOTP secret and recovery codes, e.g. after a lost phone, and log them out everywhere. If their role requires 2FA they enrol again at the next login.
+// @Tags Two-Factor
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Employee ID"
+// @Success 200 {object} models.MessageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /employees/{id}/2fa [delete]
+func (tc *TwoFactorController) Reset(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	employee, err := findVisibleEmployee(c, db)
+	if err != nil {
+		return apperror.ErrEmployeeNotFound
+	}
+
+	if err := utils.DisableTwoFactor(db, employee.ID); err != nil {
+		return apperror.Internal(err)
+	}
+	if err := utils.RevokeSessions(db, employee.ID); err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Two-factor authentication reset"})
+}
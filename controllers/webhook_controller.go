@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"attendance/apperror"
+	"attendance/models"
+	"attendance/utils"
+)
+
+type WebhookController struct{}
+
+// CreateWebhook godoc
+// @Summary Register a webhook
+// @Description Register an endpoint for the given events ("*" for all). The signing secret is only returned by this call.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param webhook body models.WebhookRequest true "Webhook"
+// @Success 200 {object} models.WebhookCreatedResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks [post]
+func (wc *WebhookController) CreateWebhook(c echo.Context) error {
+	callerID := utils.CallerID(c)
+
+	var request models.WebhookRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+	if err := validateWebhookRequest(c.Request().Context(), request); err != nil {
+		return apperror.Invalid(err)
+	}
+
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	webhook := models.Webhook{
+		URL:         request.URL,
+		Secret:      secret,
+		Events:      strings.Join(request.Events, ","),
+		Active:      request.Active == nil || *request.Active,
+		CreatedByID: callerID,
+	}
+	if err := db.Create(&webhook).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	utils.SetETag(c, webhook.Version)
+	return c.JSON(http.StatusOK, models.WebhookCreatedResponse{Webhook: webhook, Secret: secret})
+}
+
+// GetWebhooks godoc
+// @Summary List webhooks
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.Webhook
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks [get]
+func (wc *WebhookController) GetWebhooks(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var webhooks []models.Webhook
+	if err := db.Order("id").Find(&webhooks).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook
+// @Description Change the URL, subscribed events or active flag of a webhook
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Webhook ID"
+// @Param webhook body models.WebhookRequest true "Webhook"
+// @Param If-Match header string true "Version of the webhook in double quotes, as its ETag"
+// @Success 200 {object} models.Webhook
+// @Header 200 {string} ETag "Version of the webhook, for If-Match"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/{id} [put]
+func (wc *WebhookController) UpdateWebhook(c echo.Context) error {
+	var request models.WebhookRequest
+	if err := c.Bind(&request); err != nil {
+		return apperror.InvalidBody(err)
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+	if err := validateWebhookRequest(c.Request().Context(), request); err != nil {
+		return apperror.Invalid(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, c.Param("id")).Error; err != nil {
+		return apperror.ErrWebhookNotFound
+	}
+	if err := utils.CheckIfMatch(c, webhook.Version); err != nil {
+		return err
+	}
+
+	webhook.URL = request.URL
+	webhook.Events = strings.Join(request.Events, ",")
+	if request.Active != nil {
+		webhook.Active = *request.Active
+	}
+	if err := utils.SaveVersioned(db, &webhook, &webhook.Version, "url", "events", "active"); err != nil {
+		return apperror.From(err)
+	}
+
+	utils.SetETag(c, webhook.Version)
+	return c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Delete a webhook and its delivery log
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Webhook ID"
+// @Param If-Match header string true "Version of the webhook in double quotes, as its ETag"
+// @Success 200 {object} models.MessageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/{id} [delete]
+func (wc *WebhookController) DeleteWebhook(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, c.Param("id")).Error; err != nil {
+		return apperror.ErrWebhookNotFound
+	}
+	if err := utils.CheckIfMatch(c, webhook.Version); err != nil {
+		return err
+	}
+
+	err = utils.DeleteVersioned(db, &webhook, webhook.Version, func(tx *gorm.DB) error {
+		return tx.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookDelivery{}).Error
+	})
+	if err != nil {
+		return apperror.From(err)
+	}
+
+	return c.JSON(http.StatusOK, models.MessageResponse{Message: "Webhook Deleted Succesfully"})
+}
+
+// GetDeliveries godoc
+// @Summary List webhook deliveries
+// @Description The delivery log of a webhook, newest first
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Filter by status (pending, sending, delivered, failed)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Number of items per page, at most 200" default(50)
+// @Success 200 {object} models.WebhookDeliveryList
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/{id}/deliveries [get]
+func (wc *WebhookController) GetDeliveries(c echo.Context) error {
+	// the cursor is only valid for the webhook it was issued for
+	scope := "webhook-deliveries:" + c.Param("id")
+	after, limit, err := utils.ParseCursor(c, scope)
+	if err != nil {
+		return apperror.Invalid(err)
+	}
+
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	query := db.Where("webhook_id = ?", c.Param("id"))
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if err := utils.CursorQuery(query, "created_at", "id", after, limit).Find(&deliveries).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	n, page := utils.CursorPage(scope, limit, len(deliveries), func(i int) utils.Cursor {
+		return utils.Cursor{Time: deliveries[i].CreatedAt, ID: deliveries[i].ID}
+	})
+
+	return c.JSON(http.StatusOK, models.WebhookDeliveryList{Data: deliveries[:n], CursorPagination: page})
+}
+
+// ReplayDelivery godoc
+// @Summary Replay a webhook delivery
+// @Description Queue the payload of an earlier delivery again, with the same event ID
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/deliveries/{id}/replay [post]
+func (wc *WebhookController) ReplayDelivery(c echo.Context) error {
+	db, err := utils.RequestDB(c)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	var delivery models.WebhookDelivery
+	if err := db.First(&delivery, c.Param("id")).Error; err != nil {
+		return apperror.ErrDeliveryNotFound
+	}
+
+	replay, err := utils.ReplayDelivery(db, delivery)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	return c.JSON(http.StatusOK, replay)
+}
+
+func validateWebhookRequest(ctx context.Context, request models.WebhookRequest) error {
+	for _, event := range request.Events {
+		if !utils.ValidWebhookEvent(event) {
+			return fmt.Errorf("Unknown event %s", event)
+		}
+	}
+	return utils.ValidateWebhookURL(ctx, request.URL)
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"attendance/apperror"
+	"attendance/commands"
 	"attendance/controllers"
+	"attendance/models"
+	"attendance/utils"
+	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"time"
 
 	docs "attendance/docs"
 	seed "attendance/seeder"
@@ -22,11 +30,46 @@ import (
 // @Security        jwt
 func main() {
 
+	// one connection pool for the whole process
+	db, err := utils.Connect()
+	if err != nil {
+		panic("Failed to connect to database!")
+	}
+
 	//migrate and seeder
-	seed.CreateMigration()
-	seed.SeedUsers()
+	seed.CreateMigration(db)
+	seed.SeedUsers(db)
+	seed.SeedRoles(db)
+
+	// run a command line subcommand instead of the server, e.g. payroll-export
+	if len(os.Args) > 1 {
+		commands.Run(os.Args[1:])
+	}
+
+	// load (or create) the JWT signing keys before accepting logins
+	if _, err := utils.Keys(); err != nil {
+		panic("Failed to load JWT signing keys: " + err.Error())
+	}
+
+	// pagination cursors must verify on every instance and after a restart
+	if err := utils.CheckCursorSecret(); err != nil {
+		log.Fatalf("%s, e.g. from `openssl rand -hex 32`", err)
+	}
+
+	// deliver queued notifications and webhooks and schedule reminders in the background
+	go utils.StartOutboxWorker(context.Background(), db, utils.MailTransportFromEnv())
+	go utils.StartReminderScheduler(context.Background(), db)
+	go utils.StartWebhookWorker(context.Background(), db, utils.NewWebhookClient(10*time.Second))
 
 	router := echo.New()
+	router.Validator = &utils.Validator{}
+	// every error is answered as a models.ErrorResponse with a stable code
+	router.HTTPErrorHandler = apperror.Handler
+	// only honour X-Forwarded-For from proxies on loopback and private
+	// networks, so API key IP restrictions cannot be spoofed
+	router.IPExtractor = echo.ExtractIPFromXFFHeader()
+	// request IDs and audit context for every request
+	router.Use(utils.RequestContext(db))
 	// Serve Swagger UI
 	router.GET("/swagger/*", echoSwagger.WrapHandler)
 
@@ -35,23 +78,139 @@ func main() {
 	employeesController := &controllers.EmployeeController{}
 	authController := &controllers.AuthController{}
 	attendanceController := &controllers.AttendanceController{}
+	leaveController := &controllers.LeaveController{}
+	scheduleController := &controllers.ScheduleController{}
+	payrollController := &controllers.PayrollController{}
+	organizationController := &controllers.OrganizationController{}
+	roleController := &controllers.RoleController{}
+	meController := &controllers.MeController{}
+	notificationController := &controllers.NotificationController{}
+	webhookController := &controllers.WebhookController{}
+	apiKeyController := &controllers.APIKeyController{}
+	twoFactorController := &controllers.TwoFactorController{}
+	auditController := &controllers.AuditController{}
+
+	router.GET("/.well-known/jwks.json", authController.JWKS)
 
 	v1 := router.Group("/api/v1")
 
 	v1.POST("/login", authController.Login)
+	v1.POST("/login/2fa", authController.LoginTwoFactor)
 	v1.POST("/register", authController.Register)
+	v1.POST("/token/refresh", authController.RefreshToken)
+	v1.POST("/logout", authController.Logout, utils.AuthMiddleware(db))
+	v1.POST("/logout-all", authController.LogoutAll, utils.AuthMiddleware(db))
+	v1.POST("/password-reset/request", authController.RequestPasswordReset)
+	v1.POST("/password-reset/confirm", authController.ConfirmPasswordReset)
+
+	// Every group below is authenticated by AuthMiddleware. Routes list the
+	// permissions they need on top of that.
+	employees := v1.Group("/employees", utils.AuthMiddleware(db))
+	employees.POST("", employeesController.CreateEmployee, utils.RequirePermission(models.PermEmployeesCreate))
+	employees.PUT("/:id", employeesController.UpdateEmployee, utils.RequirePermission(models.PermEmployeesUpdate))
+	employees.PATCH("/:id", employeesController.PatchEmployee)
+	employees.DELETE("/:id", employeesController.DeleteEmployee, utils.RequirePermission(models.PermEmployeesDelete))
+	employees.GET("", employeesController.GetEmployees, utils.RequirePermission(models.PermEmployeesRead))
+	employees.GET("/:id", employeesController.GetEmployee, utils.RequirePermission(models.PermEmployeesRead))
+	employees.GET("/search", employeesController.SearchEmployees, utils.RequirePermission(models.PermEmployeesRead))
+	employees.POST("/import", employeesController.ImportEmployees, utils.RequirePermission(models.PermEmployeesCreate, models.PermEmployeesUpdate, models.PermVisibilityAll))
+	employees.GET("/import/template", employeesController.ImportTemplate, utils.RequirePermission(models.PermEmployeesCreate))
+	employees.GET("/:id/roles", roleController.GetEmployeeRoles, utils.RequirePermission(models.PermRolesManage))
+	employees.PUT("/:id/roles", roleController.UpdateEmployeeRoles, utils.RequirePermission(models.PermRolesManage))
+	employees.PUT("/:id/organization", organizationController.UpdatePlacement, utils.RequirePermission(models.PermOrgManage))
+	employees.GET("/:id/reports", organizationController.GetReports)
+	employees.GET("/:id/schedule", scheduleController.GetSchedule)
+	employees.PUT("/:id/schedule", scheduleController.UpdateSchedule, utils.RequirePermission(models.PermSchedulesManage))
+	employees.DELETE("/:id/2fa", twoFactorController.Reset, utils.RequirePermission(models.PermTwoFactorReset))
+	employees.POST("/:id/unlock", employeesController.UnlockEmployee, utils.RequirePermission(models.PermEmployeesUpdate))
+	employees.POST("/:id/password-reset", employeesController.SendPasswordReset, utils.RequirePermission(models.PermEmployeesUpdate))
+	employees.POST("/:id/terminate", employeesController.TerminateEmployee, utils.RequirePermission(models.PermEmployeesDelete))
+	employees.POST("/:id/suspend", employeesController.SuspendEmployee, utils.RequirePermission(models.PermEmployeesDelete))
+	employees.POST("/:id/restore", employeesController.RestoreEmployee, utils.RequirePermission(models.PermEmployeesDelete))
+
+	// self-service endpoints
+	me := v1.Group("/me", utils.AuthMiddleware(db))
+	me.GET("", meController.GetProfile)
+	me.PATCH("", meController.UpdateProfile)
+	me.PUT("/password", meController.ChangePassword)
+	me.GET("/attendance", meController.GetAttendance)
+	me.GET("/status", meController.GetStatus)
+	me.PUT("/reminders", meController.UpdateReminders)
+	me.POST("/2fa/enroll", twoFactorController.Enroll)
+	me.POST("/2fa/confirm", twoFactorController.Confirm)
+	me.POST("/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes)
+	me.DELETE("/2fa", twoFactorController.Disable)
 
-	v1.POST("/employees", employeesController.CreateEmployee)
-	v1.PUT("/employees/:id", employeesController.UpdateEmployee)
-	v1.DELETE("/employees/:id", employeesController.DeleteEmployee)
-	v1.GET("/employees", employeesController.GetEmployees)
-	v1.GET("/employees/:id", employeesController.GetEmployee)
-	v1.GET("/employees/search", employeesController.SearchEmployees)
+	// role endpoints
+	v1.GET("/permissions", roleController.GetPermissions, utils.AuthMiddleware(db), utils.RequirePermission(models.PermRolesManage))
+	roles := v1.Group("/roles", utils.AuthMiddleware(db), utils.RequirePermission(models.PermRolesManage))
+	roles.GET("", roleController.GetRoles)
+	roles.POST("", roleController.CreateRole)
+	roles.PUT("/:id", roleController.UpdateRole)
+	roles.DELETE("/:id", roleController.DeleteRole)
+	roles.PUT("/:id/two-factor", roleController.UpdateRoleTwoFactor)
+
+	// organisation endpoints
+	departments := v1.Group("/departments", utils.AuthMiddleware(db), utils.RequirePermission(models.PermOrgRead))
+	departments.GET("", organizationController.GetDepartments)
+	departments.POST("", organizationController.CreateDepartment, utils.RequirePermission(models.PermOrgManage))
+	departments.PUT("/:id", organizationController.UpdateDepartment, utils.RequirePermission(models.PermOrgManage))
+	departments.DELETE("/:id", organizationController.DeleteDepartment, utils.RequirePermission(models.PermOrgManage))
+	teams := v1.Group("/teams", utils.AuthMiddleware(db), utils.RequirePermission(models.PermOrgManage))
+	teams.POST("", organizationController.CreateTeam)
+	teams.PUT("/:id", organizationController.UpdateTeam)
+	teams.DELETE("/:id", organizationController.DeleteTeam)
+	v1.GET("/org-chart", organizationController.GetOrgChart, utils.AuthMiddleware(db), utils.RequirePermission(models.PermOrgRead))
 
 	// attendance endpoints
-	v1.POST("/attendance/clock-in/:id", attendanceController.ClockIn)
-	v1.POST("/attendance/clock-out/:id", attendanceController.ClockOut)
-	v1.GET("/attendance/work-hours/:id", attendanceController.GetWorkHours)
+	attendance := v1.Group("/attendance", utils.AuthMiddleware(db))
+	attendance.POST("/clock-in/:id", attendanceController.ClockIn, utils.RequirePermission(models.PermAttendanceClock))
+	attendance.POST("/clock-out/:id", attendanceController.ClockOut, utils.RequirePermission(models.PermAttendanceClock))
+	attendance.GET("/work-hours/:id", attendanceController.GetWorkHours, utils.RequirePermission(models.PermAttendanceClock))
+	attendance.GET("/sessions", attendanceController.GetSessions, utils.RequirePermission(models.PermAttendanceRead))
+
+	// schedule and leave endpoints
+	holidays := v1.Group("/holidays", utils.AuthMiddleware(db))
+	holidays.GET("", scheduleController.GetHolidays)
+	holidays.POST("", scheduleController.CreateHoliday, utils.RequirePermission(models.PermSchedulesManage))
+
+	leaves := v1.Group("/leaves", utils.AuthMiddleware(db))
+	leaves.POST("", leaveController.RequestLeave, utils.RequirePermission(models.PermLeaveRequest))
+	leaves.GET("", leaveController.GetLeaves)
+	leaves.PUT("/:id/approve", leaveController.ApproveLeave, utils.RequirePermission(models.PermLeaveApprove))
+	leaves.PUT("/:id/reject", leaveController.RejectLeave, utils.RequirePermission(models.PermLeaveApprove))
+
+	// payroll endpoints
+	payroll := v1.Group("/payroll", utils.AuthMiddleware(db))
+	payroll.POST("/periods", payrollController.CreatePayPeriod, utils.RequirePermission(models.PermPayrollManage))
+	payroll.GET("/periods", payrollController.GetPayPeriods, utils.RequirePermission(models.PermPayrollManage))
+	payroll.POST("/periods/:id/close", payrollController.ClosePayPeriod, utils.RequirePermission(models.PermPayrollManage))
+	payroll.GET("/periods/:id/export", payrollController.ExportPayroll, utils.RequirePermission(models.PermReportsExport))
+
+	// notification outbox endpoints
+	notifications := v1.Group("/notifications", utils.AuthMiddleware(db), utils.RequirePermission(models.PermNotificationsManage))
+	notifications.GET("", notificationController.GetNotifications)
+	notifications.POST("/:id/retry", notificationController.RetryNotification)
+
+	// webhook endpoints
+	webhooks := v1.Group("/webhooks", utils.AuthMiddleware(db), utils.RequirePermission(models.PermWebhooksManage))
+	webhooks.POST("", webhookController.CreateWebhook)
+	webhooks.GET("", webhookController.GetWebhooks)
+	webhooks.PUT("/:id", webhookController.UpdateWebhook)
+	webhooks.DELETE("/:id", webhookController.DeleteWebhook)
+	webhooks.GET("/:id/deliveries", webhookController.GetDeliveries)
+	webhooks.POST("/deliveries/:id/replay", webhookController.ReplayDelivery)
+
+	// API key endpoints
+	apiKeys := v1.Group("/api-keys", utils.AuthMiddleware(db), utils.RequirePermission(models.PermAPIKeysManage))
+	apiKeys.POST("", apiKeyController.CreateAPIKey)
+	apiKeys.GET("", apiKeyController.GetAPIKeys)
+	apiKeys.DELETE("/:id", apiKeyController.RevokeAPIKey)
+
+	// audit log endpoints
+	auditLogs := v1.Group("/audit-logs", utils.AuthMiddleware(db), utils.RequirePermission(models.PermAuditRead))
+	auditLogs.GET("", auditController.GetAuditLogs)
+	auditLogs.GET("/export", auditController.ExportAuditLogs)
 
 	// new endpoint to check if service is running
 	router.GET("/", func(c echo.Context) error {
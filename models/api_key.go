@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// APIKey lets a machine, such as the HR system or a badge reader, call the
+// API without a login. Its scopes are permission names and replace the
+// role-based permissions of a human caller. Only the SHA-256 hash of the
+// key is stored; Prefix is kept to recognise keys in listings.
+type APIKey struct {
+	Model
+	Name        string     `gorm:"not null" json:"name"`
+	Prefix      string     `gorm:"size:16;index;not null" json:"prefix"`
+	KeyHash     string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes      string     `gorm:"type:text;not null" json:"scopes"`
+	AllowedIPs  string     `gorm:"type:text" json:"allowed_ips"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `gorm:"size:64" json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedByID int        `json:"created_by_id"`
+}
+
+type APIKeyRequest struct {
+	Name       string     `json:"name" example:"Lobby badge reader" validate:"required,max=100"`
+	Scopes     []string   `json:"scopes" example:"attendance.clock" validate:"required"`
+	AllowedIPs []string   `json:"allowed_ips" example:"10.0.4.0/24"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// APIKeyCreatedResponse is the only response that includes the key.
+type APIKeyCreatedResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
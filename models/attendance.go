@@ -3,9 +3,9 @@ package models
 import "time"
 
 type ClockIn struct {
-	ID          uint      `gorm:"primary_key" json:"id"`
-	EmployeeID  int       `gorm:"not null" json:"employee_id"`
-	ClockInTime time.Time `gorm:"not null" json:"clock_in_time"`
+	ID          uint      `gorm:"primary_key;index:idx_clock_ins_cursor,priority:2" json:"id"`
+	EmployeeID  int       `gorm:"not null;index" json:"employee_id"`
+	ClockInTime time.Time `gorm:"not null;index:idx_clock_ins_cursor,priority:1" json:"clock_in_time"`
 	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
 }
 
@@ -13,7 +13,7 @@ type ClockOut struct {
 	ID           uint      `gorm:"primary_key" json:"id"`
 	EmployeeID   int       `gorm:"not null" json:"employee_id"`
 	ClockOutTime time.Time `gorm:"not null" json:"clock_out_time"`
-	ClockInID    uint
+	ClockInID    uint      `gorm:"uniqueIndex:idx_clock_outs_session"` // a clock-in is closed at most once; not named after the old plain index so existing databases get it
 	CreatedAt    time.Time `gorm:"not null" json:"created_at"`
 }
 
@@ -33,3 +33,20 @@ type ClockResponse struct {
 	Hours      int       `json:"hours"`
 	Minutes    int       `json:"minutes"`
 }
+
+// AttendanceSession pairs a clock-in with its clock-out. Open sessions have
+// no clock-out yet.
+type AttendanceSession struct {
+	ClockInID    uint       `json:"clock_in_id"`
+	ClockOutID   *uint      `json:"clock_out_id"`
+	EmployeeID   int        `json:"employee_id"`
+	ClockInTime  time.Time  `json:"clock_in_time"`
+	ClockOutTime *time.Time `json:"clock_out_time"`
+	Hours        float64    `json:"hours"`
+}
+
+// AttendanceSessionList is a page of attendance sessions.
+type AttendanceSessionList struct {
+	Data []AttendanceSession `json:"data"`
+	CursorPagination
+}
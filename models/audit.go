@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Audit actor types.
+const (
+	ActorEmployee  = "employee"
+	ActorAPIKey    = "api_key"
+	ActorAnonymous = "anonymous"
+	ActorSystem    = "system"
+)
+
+// ErrAuditAppendOnly is returned when code tries to change or remove an
+// audit entry.
+var ErrAuditAppendOnly = errors.New("audit log is append-only")
+
+// AuditLog records one write to an audited table. Entries are written by
+// the GORM callbacks in utils/audit.go and never change afterwards.
+// Changes is a JSON object mapping each changed column to its old and new
+// value.
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey;index:idx_audit_logs_cursor,priority:2" json:"id"`
+	CreatedAt time.Time `gorm:"index:idx_audit_logs_cursor,priority:1" json:"created_at"`
+	ActorType string    `gorm:"size:20;not null" json:"actor_type"`
+	ActorID   *uint     `gorm:"index" json:"actor_id"`
+	APIKeyID  *uint     `json:"api_key_id"`
+	Action    string    `gorm:"size:20;not null" json:"action"`
+	Entity    string    `gorm:"size:100;index:idx_audit_entity;not null" json:"entity"`
+	EntityID  string    `gorm:"size:100;index:idx_audit_entity" json:"entity_id"`
+	Changes   string    `gorm:"type:text" json:"changes"`
+	IP        string    `gorm:"size:64" json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	RequestID string    `gorm:"size:64;index" json:"request_id"`
+}
+
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// AuditChange is the old and new value of one column.
+type AuditChange struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// AuditLogList is a page of audit entries.
+type AuditLogList struct {
+	Data []AuditLog `json:"data"`
+	CursorPagination
+}
@@ -4,23 +4,134 @@ import (
 	"time"
 )
 
+// Employee is the persisted employee record. It is never sent to clients
+// as is; handlers answer with EmployeeResponse or ProfileResponse.
 type Employee struct {
 	Model
-	Username    string `json:"username" form:"username" binding:"required"`
-	Fullname    string `json:"fullname" form:"fullname" binding:"required"`
-	Password    string `json:"password" form:"password" binding:"required"`
-	Email       string `json:"email" form:"email" binding:"required" gorm:"unique"`
-	Role        string `json:"role" form:"role"`
-	PhoneNumber string `json:"phoneNumber" form:"phoneNumber"`
-	Address     string `json:"address" form:"address"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	Username string `json:"username"`
+	Fullname string `json:"fullname"`
+	// Password is the bcrypt hash of the password.
+	Password     string `json:"-"`
+	Email        string `json:"email" gorm:"unique"`
+	Role         string `json:"role"`
+	PhoneNumber  string `json:"phoneNumber"`
+	Address      string `json:"address"`
+	Location     string `json:"location" gorm:"size:100;index"`
+	DepartmentID *uint  `json:"departmentId" gorm:"index"`
+	TeamID       *uint  `json:"teamId" gorm:"index"`
+	ManagerID    *uint  `json:"managerId" gorm:"index"`
+	Roles        []Role `json:"roles,omitempty" gorm:"many2many:employee_roles"`
+	// RemindersOptOut stops the scheduled clock-in and clock-out reminders.
+	RemindersOptOut bool `json:"remindersOptOut" gorm:"not null;default:false"`
+	// PasswordChangedAt invalidates every token issued before it.
+	PasswordChangedAt *time.Time `json:"-"`
+	// Status is the employment status. Employees are never deleted so their
+	// attendance and payroll history stays intact.
+	Status          string     `json:"status" gorm:"size:20;not null;default:active;index"`
+	HireDate        *time.Time `json:"hireDate" gorm:"type:date"`
+	TerminationDate *time.Time `json:"terminationDate" gorm:"type:date"`
+	// Version counts the changes to the employee and is its ETag; see
+	// utils.SaveEmployee.
+	Version   uint `json:"-" gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+const (
+	EmployeeActive     = "active"
+	EmployeeSuspended  = "suspended"
+	EmployeeTerminated = "terminated"
+)
+
+// EmployeeStatuses lists every employment status.
+var EmployeeStatuses = []string{EmployeeActive, EmployeeSuspended, EmployeeTerminated}
+
+// CanWork reports whether the employee may log in and clock in at t. A
+// terminated employee keeps access until the end of the termination date.
+func (e Employee) CanWork(t time.Time) bool {
+	switch e.Status {
+	case EmployeeSuspended:
+		return false
+	case EmployeeTerminated:
+		return e.TerminationDate != nil && t.Before(e.TerminationDate.AddDate(0, 0, 1))
+	}
+	return true
+}
+
+// CreateEmployeeRequest is the body of POST /employees.
+type CreateEmployeeRequest struct {
+	Username    string `json:"username" example:"jdoe" validate:"required,username,min=3,max=50"`
+	Fullname    string `json:"fullname" example:"John Doe" validate:"required,max=100"`
+	Password    string `json:"password" validate:"required,password"`
+	Email       string `json:"email" example:"jdoe@example.com" validate:"required,email,max=100"`
+	PhoneNumber string `json:"phoneNumber" example:"+62 812 0000 0000" validate:"phone"`
+	Address     string `json:"address" validate:"max=255"`
+	Location    string `json:"location" example:"Jakarta" validate:"max=100"`
+	HireDate    string `json:"hireDate" example:"2023-05-01" validate:"date"`
+}
+
+// RegisterRequest is the body of POST /register.
+type RegisterRequest struct {
+	Username    string `json:"username" example:"jdoe" validate:"required,username,min=3,max=50"`
+	Fullname    string `json:"fullname" example:"John Doe" validate:"required,max=100"`
+	Password    string `json:"password" validate:"required,password"`
+	Email       string `json:"email" example:"jdoe@example.com" validate:"required,email,max=100"`
+	PhoneNumber string `json:"phoneNumber" example:"+62 812 0000 0000" validate:"phone"`
+	Address     string `json:"address" validate:"max=255"`
+}
+
+// UpdateEmployeeRequest is the body of PUT /employees/:id. Omitted fields
+// keep their current value. Passwords, roles, placement and employment
+// status are changed through their own endpoints.
+type UpdateEmployeeRequest struct {
+	Username    string `json:"username" example:"jdoe" validate:"required,username,min=3,max=50"`
+	Fullname    string `json:"fullname" example:"John Doe" validate:"required,max=100"`
+	Email       string `json:"email" example:"jdoe@example.com" validate:"required,email,max=100"`
+	PhoneNumber string `json:"phoneNumber" example:"+62 812 0000 0000" validate:"phone"`
+	Address     string `json:"address" validate:"max=255"`
+	Location    string `json:"location" example:"Jakarta" validate:"max=100"`
+	HireDate    string `json:"hireDate" example:"2023-05-01" validate:"date"`
+}
+
+// EmployeeResponse is how employees are shown to other employees. Phone
+// number and address are personal details: they are left out unless the
+// caller is the employee or holds employees.personal.
+type EmployeeResponse struct {
+	ID              uint      `json:"id"`
+	Username        string    `json:"username"`
+	Fullname        string    `json:"fullname"`
+	Email           string    `json:"email"`
+	Role            string    `json:"role"`
+	PhoneNumber     string    `json:"phoneNumber,omitempty"`
+	Address         string    `json:"address,omitempty"`
+	Location        string    `json:"location"`
+	DepartmentID    *uint     `json:"departmentId"`
+	TeamID          *uint     `json:"teamId"`
+	ManagerID       *uint     `json:"managerId"`
+	Status          string    `json:"status"`
+	HireDate        *string   `json:"hireDate" example:"2023-05-01"`
+	TerminationDate *string   `json:"terminationDate" example:"2023-06-30"`
+	Version         uint      `json:"version"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// EmployeeList is a page of employees.
+type EmployeeList struct {
+	Data []EmployeeResponse `json:"data"`
+	Pagination
+}
+
+// TerminationRequest ends an employee's employment. Date is the last working
+// day and defaults to today.
+type TerminationRequest struct {
+	Date string `json:"date" example:"2023-06-30" validate:"date"`
 }
 
 type LoginData struct {
 	ID       uint   `gorm:"primaryKey"`
-	Username string `json:"username" form:"username" validate:"required"`
-	Password string `json:"password" form:"password" validate:"required"`
+	Username string `json:"username" form:"username" validate:"required,max=50"`
+	Password string `json:"password" form:"password" validate:"required,max=72"`
 }
 
 type Model struct {
@@ -30,18 +141,59 @@ type Model struct {
 }
 
 type TokenResponse struct {
-	Token    string `json:"token"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
-}
-
-type CreateEmployeeResponse struct {
-	Fullname    string `json:"fullname"`
-	Username    string `json:"username"`
-	Password    string `json:"password"`
-	Email       string `json:"email"`
-	Role        string `json:"role"`
-	PhoneNumber string `json:"phoneNumber"`
-	Address     string `json:"address"`
+	Token         string    `json:"token"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	RefreshToken  string    `json:"refresh_token"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	Roles         []string  `json:"roles"`
+	RecoveryCodes []string  `json:"recovery_codes,omitempty"`
+}
+
+// ProfileResponse is the caller's own view of their employee record.
+type ProfileResponse struct {
+	ID           uint      `json:"id"`
+	Username     string    `json:"username"`
+	Fullname     string    `json:"fullname"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	Roles        []string  `json:"roles"`
+	PhoneNumber  string    `json:"phoneNumber"`
+	Address      string    `json:"address"`
+	Location     string    `json:"location"`
+	DepartmentID *uint     `json:"departmentId"`
+	TeamID       *uint     `json:"teamId"`
+	ManagerID    *uint     `json:"managerId"`
+	Reminders    bool      `json:"reminders"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// ProfileUpdateRequest holds the profile fields employees may change
+// themselves. Omitted fields are left untouched.
+type ProfileUpdateRequest struct {
+	PhoneNumber *string `json:"phoneNumber" validate:"phone"`
+	Address     *string `json:"address" validate:"max=255"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+// ClockStatusResponse tells the caller whether they are clocked in and what
+// today's schedule looks like.
+type ClockStatusResponse struct {
+	ClockedIn   bool               `json:"clocked_in"`
+	OpenSession *AttendanceSession `json:"open_session"`
+	Today       DaySchedule        `json:"today"`
+}
+
+type DaySchedule struct {
+	Date      string `json:"date"`
+	WorkDay   bool   `json:"work_day"`
+	Holiday   bool   `json:"holiday"`
+	Leave     string `json:"leave,omitempty"`
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
 }
@@ -0,0 +1,25 @@
+package models
+
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+)
+
+// EmployeeImportRow is the outcome of one CSV row. Rows are numbered like
+// lines of the file, so the first employee is row 2.
+type EmployeeImportRow struct {
+	Row      int      `json:"row"`
+	Username string   `json:"username"`
+	Action   string   `json:"action"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// EmployeeImportReport summarises an import. When any row is invalid
+// nothing is imported, dry run or not.
+type EmployeeImportReport struct {
+	DryRun  bool                `json:"dry_run"`
+	Created int                 `json:"created"`
+	Updated int                 `json:"updated"`
+	Invalid int                 `json:"invalid"`
+	Rows    []EmployeeImportRow `json:"rows"`
+}
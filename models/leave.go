@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	LeaveAnnual = "annual"
+	LeaveSick   = "sick"
+	LeaveUnpaid = "unpaid"
+	LeaveOther  = "other"
+
+	LeavePending  = "pending"
+	LeaveApproved = "approved"
+	LeaveRejected = "rejected"
+)
+
+// LeaveTypes lists every leave type in the order payroll exports them.
+var LeaveTypes = []string{LeaveAnnual, LeaveSick, LeaveUnpaid, LeaveOther}
+
+type Leave struct {
+	Model
+	EmployeeID   int       `gorm:"index;not null" json:"employee_id"`
+	Type         string    `gorm:"not null" json:"type"`
+	StartDate    time.Time `gorm:"type:date;not null" json:"start_date"`
+	EndDate      time.Time `gorm:"type:date;not null" json:"end_date"`
+	Reason       string    `json:"reason"`
+	Status       string    `gorm:"not null;default:pending" json:"status"`
+	ReviewedByID *int      `json:"reviewed_by_id"`
+	// Version is the leave request's ETag; see utils.SaveVersioned.
+	Version uint `gorm:"not null;default:0" json:"version"`
+}
+
+type LeaveRequest struct {
+	Type      string `json:"type" example:"annual" validate:"required"`
+	StartDate string `json:"start_date" example:"2023-05-01" validate:"required,date"`
+	EndDate   string `json:"end_date" example:"2023-05-03" validate:"required,date"`
+	Reason    string `json:"reason" validate:"max=500"`
+}
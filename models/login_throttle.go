@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Kinds of LoginThrottle keys.
+const (
+	ThrottleUsername = "username"
+	ThrottleIP       = "ip"
+)
+
+// LoginThrottle counts recent failed logins for a username or a client IP.
+// Rows live in the database so every server instance sees the same
+// lockouts.
+type LoginThrottle struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	Kind          string     `gorm:"size:20;not null;uniqueIndex:idx_login_throttle_key" json:"kind"`
+	Key           string     `gorm:"column:throttle_key;size:191;not null;uniqueIndex:idx_login_throttle_key" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
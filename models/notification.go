@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	NotificationPending = "pending"
+	NotificationSending = "sending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// Notification events, each with its own templates in utils/templates.
+const (
+	EventClockInReminder  = "clock_in_reminder"
+	EventClockOutReminder = "clock_out_reminder"
+	EventPasswordReset    = "password_reset"
+	EventAccountLocked    = "account_locked"
+	EventInvitation       = "invitation"
+)
+
+// Notification is a rendered email waiting in, or delivered from, the
+// outbox. The background sender in utils.StartOutboxWorker works the queue.
+type Notification struct {
+	ID            uint       `gorm:"primarykey;index:idx_notifications_cursor,priority:2" json:"id"`
+	Event         string     `gorm:"size:100;index;not null" json:"event"`
+	Recipient     string     `gorm:"not null" json:"recipient"`
+	Subject       string     `gorm:"not null" json:"subject"`
+	TextBody      string     `gorm:"type:text" json:"-"`
+	HTMLBody      string     `gorm:"type:text" json:"-"`
+	Status        string     `gorm:"size:20;index;not null;default:pending" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index;not null" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `gorm:"index:idx_notifications_cursor,priority:1" json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// NotificationList is a page of outbox entries.
+type NotificationList struct {
+	Data []Notification `json:"data"`
+	CursorPagination
+}
@@ -0,0 +1,62 @@
+package models
+
+// Department is a node of the organisational tree. Top-level departments
+// have no parent.
+type Department struct {
+	Model
+	Name     string `gorm:"not null" json:"name"`
+	ParentID *uint  `gorm:"index" json:"parent_id"`
+	// Version is the department's ETag; see utils.SaveVersioned.
+	Version uint `gorm:"not null;default:0" json:"version"`
+}
+
+// Team belongs to a department and may itself be nested in another team.
+type Team struct {
+	Model
+	Name         string `gorm:"not null" json:"name"`
+	DepartmentID uint   `gorm:"index;not null" json:"department_id"`
+	ParentID     *uint  `gorm:"index" json:"parent_id"`
+	// Version is the team's ETag; see utils.SaveVersioned.
+	Version uint `gorm:"not null;default:0" json:"version"`
+}
+
+type DepartmentRequest struct {
+	Name     string `json:"name" example:"Engineering" validate:"required,max=100"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type TeamRequest struct {
+	Name         string `json:"name" example:"Platform" validate:"required,max=100"`
+	DepartmentID uint   `json:"department_id" validate:"required"`
+	ParentID     *uint  `json:"parent_id"`
+}
+
+// PlacementRequest moves an employee within the organisation. Null values
+// clear the corresponding reference.
+type PlacementRequest struct {
+	DepartmentID *uint `json:"department_id"`
+	TeamID       *uint `json:"team_id"`
+	ManagerID    *uint `json:"manager_id"`
+}
+
+type DepartmentNode struct {
+	Department
+	Teams    []TeamNode       `json:"teams"`
+	Children []DepartmentNode `json:"children"`
+}
+
+type TeamNode struct {
+	Team
+	Children []TeamNode `json:"children"`
+}
+
+// OrgChartNode is one employee in the reporting tree.
+type OrgChartNode struct {
+	ID           uint           `json:"id"`
+	Fullname     string         `json:"fullname"`
+	Username     string         `json:"username"`
+	Role         string         `json:"role"`
+	DepartmentID *uint          `json:"department_id"`
+	TeamID       *uint          `json:"team_id"`
+	Reports      []OrgChartNode `json:"reports"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PasswordResetToken is a single-use password reset token. Only the SHA-256
+// hash of the token is stored; the token itself is only sent by email.
+type PasswordResetToken struct {
+	ID         uint      `gorm:"primarykey"`
+	EmployeeID uint      `gorm:"index;not null"`
+	TokenHash  string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	UsedAt     *time.Time
+	CreatedAt  time.Time
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" example:"jhon@gmail.com" validate:"required,email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,password"`
+}
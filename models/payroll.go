@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	PayPeriodOpen   = "open"
+	PayPeriodClosed = "closed"
+)
+
+// PayPeriod groups attendance into a payroll run. Once closed, the computed
+// rows are frozen in Snapshot so later exports stay identical.
+type PayPeriod struct {
+	Model
+	Name      string     `gorm:"not null" json:"name"`
+	StartDate time.Time  `gorm:"type:date;not null" json:"start_date"`
+	EndDate   time.Time  `gorm:"type:date;not null" json:"end_date"`
+	Status    string     `gorm:"not null;default:open" json:"status"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	Snapshot  string     `gorm:"type:longtext" json:"-"`
+}
+
+type PayPeriodRequest struct {
+	Name      string `json:"name" example:"May 2023"`
+	StartDate string `json:"start_date" example:"2023-05-01"`
+	EndDate   string `json:"end_date" example:"2023-05-31"`
+}
+
+// PayrollRow is one employee's totals for a pay period. Overtime is keyed by
+// pay rate and Leave by leave type.
+type PayrollRow struct {
+	EmployeeID   int                `json:"employee_id"`
+	Username     string             `json:"username"`
+	Fullname     string             `json:"fullname"`
+	RegularHours float64            `json:"regular_hours"`
+	Overtime     map[string]float64 `json:"overtime"`
+	Leave        map[string]float64 `json:"leave"`
+	Absences     int                `json:"absences"`
+	LateMinutes  int                `json:"late_minutes"`
+}
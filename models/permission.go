@@ -0,0 +1,109 @@
+package models
+
+// Permission names checked by utils.RequirePermission.
+const (
+	PermEmployeesRead       = "employees.read"
+	PermEmployeesCreate     = "employees.create"
+	PermEmployeesUpdate     = "employees.update"
+	PermEmployeesDelete     = "employees.delete"
+	PermEmployeesPersonal   = "employees.personal"
+	PermAttendanceClock     = "attendance.clock"
+	PermAttendanceRead      = "attendance.read"
+	PermAttendanceEdit      = "attendance.edit"
+	PermLeaveRequest        = "leave.request"
+	PermLeaveApprove        = "leave.approve"
+	PermSchedulesManage     = "schedules.manage"
+	PermOrgRead             = "organization.read"
+	PermOrgManage           = "organization.manage"
+	PermPayrollManage       = "payroll.manage"
+	PermReportsExport       = "reports.export"
+	PermRolesManage         = "roles.manage"
+	PermNotificationsManage = "notifications.manage"
+	PermWebhooksManage      = "webhooks.manage"
+	PermAPIKeysManage       = "api_keys.manage"
+	PermTwoFactorReset      = "two_factor.reset"
+	PermAuditRead           = "audit.read"
+	// PermVisibilityAll lifts the reporting-subtree restriction on employee,
+	// attendance and leave queries.
+	PermVisibilityAll = "visibility.all"
+)
+
+// Built-in role names. They are recreated on every start and cannot be
+// edited or deleted through the API.
+const (
+	RoleEmployee    = "employee"
+	RoleManager     = "manager"
+	RoleHRAdmin     = "hr_admin"
+	RoleAuditor     = "auditor"
+	RoleSystemAdmin = "system_admin"
+)
+
+// Permissions describes every permission known to the system.
+var Permissions = map[string]string{
+	PermEmployeesRead:       "View employee records",
+	PermEmployeesCreate:     "Create employees",
+	PermEmployeesUpdate:     "Edit employee records",
+	PermEmployeesDelete:     "Terminate, suspend and restore employees",
+	PermEmployeesPersonal:   "View phone numbers and addresses of other employees",
+	PermAttendanceClock:     "Clock in and out",
+	PermAttendanceRead:      "View attendance of other employees",
+	PermAttendanceEdit:      "Correct attendance records",
+	PermLeaveRequest:        "Request leave",
+	PermLeaveApprove:        "Approve or reject leave requests",
+	PermSchedulesManage:     "Manage work schedules and holidays",
+	PermOrgRead:             "View departments, teams and the org chart",
+	PermOrgManage:           "Manage departments, teams and reporting lines",
+	PermPayrollManage:       "Manage pay periods",
+	PermReportsExport:       "Export payroll and reports",
+	PermRolesManage:         "Manage roles and role assignments",
+	PermNotificationsManage: "View and retry outgoing notifications",
+	PermWebhooksManage:      "Manage webhooks and replay deliveries",
+	PermAPIKeysManage:       "Create and revoke API keys",
+	PermTwoFactorReset:      "Reset another employee's two-factor authentication",
+	PermAuditRead:           "Search and export the audit log",
+	PermVisibilityAll:       "See every employee instead of only the reporting subtree",
+}
+
+// BuiltInRoles maps each built-in role to its permissions.
+var BuiltInRoles = map[string][]string{
+	RoleEmployee: {PermAttendanceClock, PermLeaveRequest, PermOrgRead},
+	RoleManager: {PermAttendanceClock, PermLeaveRequest, PermOrgRead,
+		PermEmployeesRead, PermAttendanceRead, PermLeaveApprove},
+	RoleHRAdmin: {PermAttendanceClock, PermLeaveRequest, PermOrgRead,
+		PermEmployeesRead, PermEmployeesCreate, PermEmployeesUpdate, PermEmployeesDelete, PermEmployeesPersonal,
+		PermAttendanceRead, PermAttendanceEdit, PermLeaveApprove, PermSchedulesManage,
+		PermOrgManage, PermPayrollManage, PermReportsExport, PermVisibilityAll, PermNotificationsManage},
+	RoleAuditor: {PermOrgRead, PermEmployeesRead, PermAttendanceRead, PermReportsExport, PermVisibilityAll, PermAuditRead},
+	RoleSystemAdmin: {PermEmployeesRead, PermEmployeesCreate, PermEmployeesUpdate, PermEmployeesDelete, PermEmployeesPersonal,
+		PermAttendanceClock, PermAttendanceRead, PermAttendanceEdit, PermLeaveRequest, PermLeaveApprove,
+		PermSchedulesManage, PermOrgRead, PermOrgManage, PermPayrollManage, PermReportsExport, PermRolesManage,
+		PermVisibilityAll, PermNotificationsManage, PermWebhooksManage, PermAPIKeysManage, PermTwoFactorReset, PermAuditRead},
+}
+
+type Permission struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	Name        string `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+}
+
+type Role struct {
+	Model
+	Name             string       `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Description      string       `json:"description"`
+	BuiltIn          bool         `gorm:"not null;default:false" json:"built_in"`
+	RequireTwoFactor bool         `gorm:"not null;default:false" json:"require_two_factor"`
+	Permissions      []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	// Version is the role's ETag; see utils.SaveVersioned.
+	Version uint `gorm:"not null;default:0" json:"version"`
+}
+
+type RoleRequest struct {
+	Name             string   `json:"name" example:"shift_lead" validate:"required,username,max=50"`
+	Description      string   `json:"description" validate:"max=255"`
+	Permissions      []string `json:"permissions" example:"attendance.read,leave.approve"`
+	RequireTwoFactor bool     `json:"require_two_factor"`
+}
+
+type RoleAssignmentRequest struct {
+	Roles []string `json:"roles" example:"employee,manager"`
+}
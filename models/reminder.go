@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	ReminderClockIn  = "clock_in"
+	ReminderClockOut = "clock_out"
+)
+
+// ReminderLog records that a reminder went out so that each reminder is
+// sent at most once per employee and day, even with several instances.
+type ReminderLog struct {
+	ID         uint   `gorm:"primarykey"`
+	EmployeeID uint   `gorm:"uniqueIndex:idx_reminder_once;not null"`
+	Kind       string `gorm:"size:20;uniqueIndex:idx_reminder_once;not null"`
+	Date       string `gorm:"size:10;uniqueIndex:idx_reminder_once;not null"`
+	CreatedAt  time.Time
+}
+
+type ReminderPreferenceRequest struct {
+	Enabled bool `json:"enabled"`
+}
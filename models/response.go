@@ -1,9 +1,50 @@
 package models
 
+// ErrorResponse is the body of every error. Code is stable and meant for
+// programs, Error is a message for people. RequestID matches the
+// X-Request-ID header and the server log.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error     string      `json:"error" example:"Employee not found"`
+	Code      string      `json:"code" example:"EMPLOYEE_NOT_FOUND"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty" example:"Vx3q9ZkR2mPa7LcN"`
 }
 
 type MessageResponse struct {
 	Message string `json:"message"`
 }
+
+// FieldError says why a request field is invalid. Field is the JSON name
+// of the field and Rule the failed validation rule, e.g. required or email.
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Rule    string `json:"rule" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
+}
+
+// ValidationErrorResponse is the 422 body of a request with invalid fields,
+// an ErrorResponse whose details list the fields.
+type ValidationErrorResponse struct {
+	Error     string       `json:"error" example:"Validation failed"`
+	Code      string       `json:"code" example:"VALIDATION_FAILED"`
+	Details   []FieldError `json:"details"`
+	RequestID string       `json:"request_id,omitempty" example:"Vx3q9ZkR2mPa7LcN"`
+}
+
+// Pagination describes the page of a list response. Next and Prev link to
+// the neighbouring pages and are left out at either end.
+type Pagination struct {
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// CursorPagination describes a page of a cursor paginated list. Pass
+// NextCursor as the cursor parameter to get the next page; it is null on
+// the last page.
+type CursorPagination struct {
+	NextCursor *string `json:"next_cursor"`
+	Limit      int     `json:"limit"`
+}
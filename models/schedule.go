@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// WorkSchedule describes the hours an employee is expected to work.
+// Employees without a schedule fall back to utils.DefaultSchedule.
+type WorkSchedule struct {
+	Model
+	EmployeeID int    `gorm:"uniqueIndex;not null" json:"employee_id"`
+	StartTime  string `gorm:"not null" json:"start_time" example:"09:00"`
+	EndTime    string `gorm:"not null" json:"end_time" example:"17:00"`
+	WorkDays   string `gorm:"not null" json:"work_days" example:"1,2,3,4,5"` // weekday numbers, 0 is Sunday
+	Version    uint   `gorm:"not null;default:0" json:"version"`
+}
+
+type Holiday struct {
+	Model
+	Date time.Time `gorm:"type:date;uniqueIndex;not null" json:"date"`
+	Name string    `gorm:"not null" json:"name"`
+}
+
+type ScheduleRequest struct {
+	StartTime string `json:"start_time" example:"09:00" validate:"required"`
+	EndTime   string `json:"end_time" example:"17:00" validate:"required"`
+	WorkDays  string `json:"work_days" example:"1,2,3,4,5" validate:"required"`
+}
+
+type HolidayRequest struct {
+	Date string `json:"date" example:"2023-12-25"`
+	Name string `json:"name" example:"Christmas Day"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AuthSession is one login of an employee. Access tokens carry the session
+// ID in their "sid" claim, so revoking the session logs out every token
+// issued for it.
+type AuthSession struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	EmployeeID uint       `gorm:"index;not null" json:"employee_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `gorm:"size:64" json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// RefreshToken is a single-use refresh token of a session. Every refresh
+// consumes the token and issues a new one; presenting a consumed token
+// again revokes the whole session. Only the SHA-256 hash is stored.
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey"`
+	SessionID uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
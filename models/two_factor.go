@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Values of TwoFactorChallengeResponse.TwoFactor.
+const (
+	TwoFactorRequired           = "required"
+	TwoFactorEnrollmentRequired = "enrollment_required"
+)
+
+// TwoFactor is an employee's TOTP secret. It only guards logins once
+// EnabledAt is set, which happens when the first code is confirmed.
+// LastUsedStep stops a code from being used twice.
+type TwoFactor struct {
+	ID           uint   `gorm:"primarykey"`
+	EmployeeID   uint   `gorm:"uniqueIndex;not null"`
+	Secret       string `gorm:"size:64;not null"`
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code. Only
+// the SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID         uint   `gorm:"primarykey"`
+	EmployeeID uint   `gorm:"index;not null"`
+	CodeHash   string `gorm:"size:64;index;not null"`
+	UsedAt     *time.Time
+	CreatedAt  time.Time
+}
+
+// LoginChallenge links the password step of a login to its second step.
+type LoginChallenge struct {
+	ID         uint      `gorm:"primarykey"`
+	EmployeeID uint      `gorm:"index;not null"`
+	TokenHash  string    `gorm:"size:64;uniqueIndex;not null"`
+	Attempts   int       `gorm:"not null;default:0"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	UsedAt     *time.Time
+	CreatedAt  time.Time
+}
+
+// TwoFactorChallengeResponse is returned by login instead of tokens when a
+// second factor is needed. For enrollment the TOTP secret is included so
+// the employee can add it to an authenticator app first.
+type TwoFactorChallengeResponse struct {
+	TwoFactor       string `json:"two_factor" example:"required"`
+	ChallengeToken  string `json:"challenge_token"`
+	Secret          string `json:"secret,omitempty"`
+	ProvisioningURI string `json:"provisioning_uri,omitempty"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code" example:"123456"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" example:"123456"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorRoleRequest struct {
+	Required bool `json:"required"`
+}
@@ -0,0 +1,96 @@
+package models
+
+import "time"
+
+// Webhook event types.
+const (
+	EventClockIn            = "clock_in"
+	EventClockOut           = "clock_out"
+	EventEmployeeCreated    = "employee.created"
+	EventEmployeeDeleted    = "employee.deleted"
+	EventEmployeeTerminated = "employee.terminated"
+	EventEmployeeSuspended  = "employee.suspended"
+	EventEmployeeRestored   = "employee.restored"
+	EventLeaveApproved      = "leave.approved"
+	EventLeaveRejected      = "leave.rejected"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to. "*"
+// subscribes to all of them. EventEmployeeDeleted predates terminations and
+// is still sent, next to EventEmployeeTerminated, for DELETE /employees/:id.
+var WebhookEvents = []string{EventClockIn, EventClockOut, EventEmployeeCreated, EventEmployeeDeleted, EventEmployeeTerminated, EventEmployeeSuspended, EventEmployeeRestored, EventLeaveApproved, EventLeaveRejected}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint that receives signed JSON payloads for the events
+// it subscribes to. Events is a comma separated list.
+type Webhook struct {
+	Model
+	URL         string `gorm:"not null" json:"url"`
+	Secret      string `gorm:"not null" json:"-"`
+	Events      string `gorm:"type:text;not null" json:"events"`
+	Active      bool   `gorm:"not null;default:true" json:"active"`
+	CreatedByID int    `json:"created_by_id"`
+	// Version is the webhook's ETag; see utils.SaveVersioned.
+	Version uint `gorm:"not null;default:0" json:"version"`
+}
+
+// WebhookDelivery is one attempt series to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primarykey;index:idx_webhook_deliveries_cursor,priority:3" json:"id"`
+	WebhookID      uint       `gorm:"index:idx_webhook_deliveries_cursor,priority:1;not null" json:"webhook_id"`
+	EventID        string     `gorm:"size:64;index;not null" json:"event_id"`
+	Event          string     `gorm:"size:100;not null" json:"event"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"size:20;index;not null;default:pending" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index;not null" json:"next_attempt_at"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body"`
+	LastError      string     `gorm:"type:text" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `gorm:"index:idx_webhook_deliveries_cursor,priority:2" json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url" example:"https://tools.example.com/hooks/attendance" validate:"required,url"`
+	Events []string `json:"events" example:"clock_in,clock_out" validate:"required,min=1"`
+	Active *bool    `json:"active"`
+}
+
+// WebhookCreatedResponse is the only response that includes the signing
+// secret.
+type WebhookCreatedResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// EmployeeEventData is the data of the employee.* events.
+type EmployeeEventData struct {
+	ID              uint       `json:"id"`
+	Username        string     `json:"username"`
+	Fullname        string     `json:"fullname"`
+	Email           string     `json:"email"`
+	Status          string     `json:"status"`
+	TerminationDate *time.Time `json:"termination_date,omitempty"`
+}
+
+// WebhookPayload is the JSON body posted to webhooks.
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDeliveryList is a page of a webhook's delivery log.
+type WebhookDeliveryList struct {
+	Data []WebhookDelivery `json:"data"`
+	CursorPagination
+}
@@ -2,15 +2,19 @@ package seeder
 
 import (
 	"attendance/models"
-	"attendance/utils"
-)
 
-func CreateMigration() {
-	db, err := utils.Connect()
-	if err != nil {
-		panic("Failed to connect to database!")
-	}
+	"gorm.io/gorm"
+)
 
+func CreateMigration(db *gorm.DB) {
 	// Auto migrate all entities
-	db.AutoMigrate(&models.Employee{}, &models.ClockIn{}, &models.ClockOut{}, &models.WorkingHours{})
+	db.AutoMigrate(&models.Employee{}, &models.ClockIn{}, &models.ClockOut{}, &models.WorkingHours{},
+		&models.WorkSchedule{}, &models.Holiday{}, &models.Leave{}, &models.PayPeriod{},
+		&models.Department{}, &models.Team{},
+		&models.Permission{}, &models.Role{}, &models.PasswordResetToken{},
+		&models.Notification{}, &models.ReminderLog{},
+		&models.Webhook{}, &models.WebhookDelivery{},
+		&models.AuthSession{}, &models.RefreshToken{}, &models.APIKey{},
+		&models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginChallenge{},
+		&models.LoginThrottle{}, &models.AuditLog{})
 }
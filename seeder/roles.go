@@ -0,0 +1,54 @@
+package seeder
+
+import (
+	"log"
+
+	"attendance/models"
+	"attendance/utils"
+
+	"gorm.io/gorm"
+)
+
+// SeedRoles creates the permissions and built-in roles, and gives every
+// employee without a role assignment one based on their legacy role column.
+func SeedRoles(db *gorm.DB) {
+	permissions := map[string]models.Permission{}
+	for name, description := range models.Permissions {
+		permission := models.Permission{Name: name}
+		if err := db.Where(models.Permission{Name: name}).Assign(models.Permission{Description: description}).FirstOrCreate(&permission).Error; err != nil {
+			log.Fatalf("failed to seed permissions: %s", err.Error())
+		}
+		permissions[name] = permission
+	}
+
+	for name, granted := range models.BuiltInRoles {
+		role := models.Role{Name: name}
+		if err := db.Where(models.Role{Name: name}).Assign(models.Role{BuiltIn: true}).FirstOrCreate(&role).Error; err != nil {
+			log.Fatalf("failed to seed roles: %s", err.Error())
+		}
+		rolePermissions := make([]models.Permission, 0, len(granted))
+		for _, permission := range granted {
+			rolePermissions = append(rolePermissions, permissions[permission])
+		}
+		if err := db.Model(&role).Association("Permissions").Replace(rolePermissions); err != nil {
+			log.Fatalf("failed to seed role permissions: %s", err.Error())
+		}
+	}
+
+	var unassigned []models.Employee
+	err := db.Where("id NOT IN (?)", db.Table("employee_roles").Select("employee_id")).Find(&unassigned).Error
+	if err != nil {
+		log.Fatalf("failed to load employees: %s", err.Error())
+	}
+	for i := range unassigned {
+		role := models.RoleEmployee
+		if unassigned[i].Role == "admin" {
+			role = models.RoleSystemAdmin
+		}
+		if err := utils.AssignRoles(db, &unassigned[i], []string{role}); err != nil {
+			log.Fatalf("failed to assign roles: %s", err.Error())
+		}
+	}
+
+	log.Println("roles seeded")
+}
@@ -4,15 +4,11 @@ import (
 	"log"
 
 	"attendance/models"
-	"attendance/utils"
-)
 
-func SeedUsers() {
-	db, err := utils.Connect()
-	if err != nil {
-		log.Fatalf("failed to connect database: %s", err.Error())
-	}
+	"gorm.io/gorm"
+)
 
+func SeedUsers(db *gorm.DB) {
 	// check if any user already exists in the database
 	var user models.Employee
 	if db.First(&user).Error == nil {
@@ -32,7 +28,7 @@ func SeedUsers() {
 
 	for i := range users {
 		users[i].ID = uint(i) + 1
-		err = db.Create(&users[i]).Error
+		err := db.Create(&users[i]).Error
 		if err != nil {
 			log.Fatalf("failed to seed users: %s", err.Error())
 		}
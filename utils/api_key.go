@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyHeader carries API keys. Requests with it skip the bearer token.
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyPrefix = "ak_"
+
+// ErrInvalidAPIKey is returned for unknown, revoked and expired keys and
+// for keys used from an address they are not allowed from.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// GenerateAPIKey returns a new plain API key.
+func GenerateAPIKey() (string, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + token, nil
+}
+
+// APIKeyDisplayPrefix is the part of a key kept in plain text to tell keys
+// apart.
+func APIKeyDisplayPrefix(key string) string {
+	if len(key) > 11 {
+		return key[:11]
+	}
+	return key
+}
+
+// ValidateAllowedIPs checks that every entry is an IP address or a CIDR
+// range.
+func ValidateAllowedIPs(entries []string) error {
+	for _, entry := range entries {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		if net.ParseIP(entry) == nil {
+			return fmt.Errorf("Invalid IP address or range %s", entry)
+		}
+	}
+	return nil
+}
+
+// AuthenticateAPIKey looks up a key, checks its expiry and IP restriction
+// and returns the principal it acts as.
+func AuthenticateAPIKey(db *gorm.DB, key, ip string) (*Principal, error) {
+	var apiKey models.APIKey
+	if err := db.Where("key_hash = ? AND revoked_at IS NULL", HashToken(key)).First(&apiKey).Error; err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ipAllowed(apiKey.AllowedIPs, ip) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// last-used tracking is coarse to spare a write on every request
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > time.Minute || apiKey.LastUsedIP != ip {
+		db.Model(&apiKey).UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+
+	granted := map[string]bool{}
+	for _, scope := range splitList(apiKey.Scopes) {
+		granted[scope] = true
+	}
+	return &Principal{APIKeyID: apiKey.ID, Permissions: granted}, nil
+}
+
+func ipAllowed(allowed, ip string) bool {
+	entries := splitList(allowed)
+	if len(entries) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range entries {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowedAddr := net.ParseIP(entry); allowedAddr != nil && allowedAddr.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
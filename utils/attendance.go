@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+// SessionQuery selects attendance sessions, joining every clock-in with its
+// clock-out. Callers add their own filters on clock_ins columns.
+func SessionQuery(db *gorm.DB) *gorm.DB {
+	return db.Table("clock_ins").
+		Select("clock_ins.id AS clock_in_id, clock_outs.id AS clock_out_id, clock_ins.employee_id, clock_ins.clock_in_time, clock_outs.clock_out_time").
+		Joins("LEFT JOIN clock_outs ON clock_outs.clock_in_id = clock_ins.id")
+}
+
+// AttendanceSessions runs a SessionQuery and fills in the hours worked of
+// every closed session.
+func AttendanceSessions(query *gorm.DB) ([]models.AttendanceSession, error) {
+	var sessions []models.AttendanceSession
+	if err := query.Scan(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for i, session := range sessions {
+		if session.ClockOutTime != nil {
+			sessions[i].Hours = roundHours(session.ClockOutTime.Sub(session.ClockInTime).Hours())
+		}
+	}
+	return sessions, nil
+}
+
+// OpenSession returns the employee's latest clock-in that has no clock-out
+// yet, or nil when the employee is not clocked in.
+func OpenSession(db *gorm.DB, employeeID int) (*models.AttendanceSession, error) {
+	sessions, err := AttendanceSessions(SessionQuery(db).
+		Where("clock_ins.employee_id = ? AND clock_outs.id IS NULL", employeeID).
+		Order("clock_ins.clock_in_time DESC").
+		Limit(1))
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+	return &sessions[0], nil
+}
@@ -0,0 +1,425 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// AuditedTables are the tables whose writes end up in the audit log.
+var AuditedTables = map[string]bool{
+	"employees": true, "employee_roles": true, "roles": true, "role_permissions": true,
+	"clock_ins": true, "clock_outs": true, "working_hours": true, "leaves": true,
+	"work_schedules": true, "holidays": true, "pay_periods": true,
+	"departments": true, "teams": true, "webhooks": true, "api_keys": true, "two_factors": true,
+}
+
+// auditRedacted columns are logged as changed without their values.
+var auditRedacted = map[string]bool{"password": true, "secret": true, "key_hash": true}
+
+// auditIgnored columns do not make a change worth logging on their own.
+var auditIgnored = map[string]bool{"updated_at": true, "last_used_at": true, "last_used_ip": true, "last_used_step": true}
+
+// auditBatchSize is how many rows the audit loads or writes per query.
+// Bulk writes are audited in full, a batch at a time.
+const auditBatchSize = 500
+
+type auditContextKey struct{}
+
+const databaseKey = "database"
+
+// AuditInfo describes who is behind the writes of a request. RequestContext
+// stores it in the request context and AuthMiddleware fills in the actor.
+type AuditInfo struct {
+	ActorType string
+	ActorID   *uint
+	APIKeyID  *uint
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// RequestContext assigns every request an ID, echoed in the X-Request-ID
+// header, puts the AuditInfo of the request in its context and hands db,
+// the connection pool shared by all requests, to RequestDB.
+func RequestContext(db *gorm.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(databaseKey, db)
+			requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+			if requestID == "" || len(requestID) > 64 {
+				requestID, _ = RandomToken(12)
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+			info := &AuditInfo{
+				ActorType: models.ActorAnonymous,
+				IP:        c.RealIP(),
+				UserAgent: c.Request().UserAgent(),
+				RequestID: requestID,
+			}
+			c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), auditContextKey{}, info)))
+			return next(c)
+		}
+	}
+}
+
+// setAuditActor records the authenticated caller as the actor of the
+// request's writes.
+func setAuditActor(c echo.Context, principal *Principal) {
+	info, ok := c.Request().Context().Value(auditContextKey{}).(*AuditInfo)
+	if !ok {
+		return
+	}
+	if principal.IsAPIKey() {
+		id := principal.APIKeyID
+		info.ActorType, info.APIKeyID = models.ActorAPIKey, &id
+	} else {
+		id := uint(principal.EmployeeID)
+		info.ActorType, info.ActorID = models.ActorEmployee, &id
+	}
+}
+
+// RequestDB returns the shared database handle with the request's context,
+// so writes are attributed to the caller in the audit log.
+func RequestDB(c echo.Context) (*gorm.DB, error) {
+	db, ok := c.Get(databaseKey).(*gorm.DB)
+	if !ok {
+		return nil, errors.New("no database for the request, RequestContext is not installed")
+	}
+	return db.WithContext(c.Request().Context()), nil
+}
+
+func registerAuditCallbacks(db *gorm.DB) error {
+	callbacks := []error{
+		db.Callback().Create().After("gorm:create").Register("audit:create", auditCreate),
+		db.Callback().Update().Before("gorm:update").Register("audit:before_update", auditSnapshot),
+		db.Callback().Update().After("gorm:update").Register("audit:update", auditUpdate),
+		db.Callback().Delete().Before("gorm:delete").Register("audit:before_delete", auditSnapshot),
+		db.Callback().Delete().After("gorm:delete").Register("audit:delete", auditDelete),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func audited(db *gorm.DB) bool {
+	return db.Error == nil && db.Statement.Schema != nil && AuditedTables[db.Statement.Table]
+}
+
+func auditCreate(db *gorm.DB) {
+	if !audited(db) || db.Statement.RowsAffected == 0 {
+		return
+	}
+
+	var entries []models.AuditLog
+	forEachRecord(db.Statement.ReflectValue, func(record reflect.Value) {
+		row := map[string]interface{}{}
+		for _, field := range db.Statement.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if value, zero := field.ValueOf(db.Statement.Context, record); !zero {
+				row[field.DBName] = value
+			}
+		}
+		if changes := auditDiff(nil, row); changes != "" {
+			entries = append(entries, auditEntry(db, models.AuditCreate, row, changes))
+		}
+	})
+	writeAudit(db, entries)
+}
+
+func auditUpdate(db *gorm.DB) {
+	before, ok := db.InstanceGet("audit:before")
+	if !ok || !audited(db) || db.Statement.RowsAffected == 0 {
+		return
+	}
+	rows := before.([]map[string]interface{})
+	pk := primaryKey(db)
+	if pk == "" || len(rows) == 0 {
+		return
+	}
+
+	afterByID := map[string]map[string]interface{}{}
+	for start := 0; start < len(rows); start += auditBatchSize {
+		end := start + auditBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		ids := make([]interface{}, 0, end-start)
+		for _, row := range rows[start:end] {
+			ids = append(ids, row[pk])
+		}
+		var after []map[string]interface{}
+		if err := db.Session(&gorm.Session{NewDB: true}).Table(db.Statement.Table).
+			Where(clause.IN{Column: clause.Column{Name: pk}, Values: ids}).Find(&after).Error; err != nil {
+			db.AddError(err)
+			return
+		}
+		for _, row := range after {
+			afterByID[fmt.Sprint(normalizeAuditValue(row[pk]))] = row
+		}
+	}
+
+	var entries []models.AuditLog
+	for _, row := range rows {
+		if changes := auditDiff(row, afterByID[fmt.Sprint(normalizeAuditValue(row[pk]))]); changes != "" {
+			entries = append(entries, auditEntry(db, models.AuditUpdate, row, changes))
+		}
+	}
+	writeAudit(db, entries)
+}
+
+func auditDelete(db *gorm.DB) {
+	before, ok := db.InstanceGet("audit:before")
+	if !ok || !audited(db) || db.Statement.RowsAffected == 0 {
+		return
+	}
+
+	var entries []models.AuditLog
+	for _, row := range before.([]map[string]interface{}) {
+		if changes := auditDiff(row, nil); changes != "" {
+			entries = append(entries, auditEntry(db, models.AuditDelete, row, changes))
+		}
+	}
+	writeAudit(db, entries)
+}
+
+// auditSnapshot loads the rows an update or delete is about to touch,
+// using the statement's conditions plus the primary key of the model.
+func auditSnapshot(db *gorm.DB) {
+	if !audited(db) {
+		return
+	}
+	stmt := db.Statement
+
+	var conditions []clause.Expression
+	if where, ok := stmt.Clauses["WHERE"]; ok {
+		if expr, ok := where.Expression.(clause.Where); ok {
+			conditions = append(conditions, expr.Exprs...)
+		}
+	}
+	if stmt.ReflectValue.IsValid() && (stmt.ReflectValue.Kind() == reflect.Struct || stmt.ReflectValue.Kind() == reflect.Slice) {
+		_, values := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields)
+		if column, queryValues := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, values); len(queryValues) > 0 {
+			conditions = append(conditions, clause.IN{Column: column, Values: queryValues})
+		}
+	}
+	if len(conditions) == 0 {
+		return
+	}
+
+	query := func() *gorm.DB {
+		return db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).Clauses(clause.Where{Exprs: conditions})
+	}
+	var rows []map[string]interface{}
+	pk := primaryKey(db)
+	if pk == "" {
+		// join tables, whose writes only ever touch a few rows
+		if err := query().Find(&rows).Error; err != nil {
+			db.AddError(err)
+			return
+		}
+		db.InstanceSet("audit:before", rows)
+		return
+	}
+	for {
+		page := query().Order(clause.OrderByColumn{Column: clause.Column{Name: pk}}).Limit(auditBatchSize)
+		if len(rows) > 0 {
+			page = page.Where(clause.Gt{Column: clause.Column{Name: pk}, Value: rows[len(rows)-1][pk]})
+		}
+		var batch []map[string]interface{}
+		if err := page.Find(&batch).Error; err != nil {
+			db.AddError(err)
+			return
+		}
+		rows = append(rows, batch...)
+		if len(batch) < auditBatchSize {
+			break
+		}
+	}
+	db.InstanceSet("audit:before", rows)
+}
+
+// auditDiff returns the JSON changes between two versions of a row; either
+// side is nil for creates and deletes. It is empty when nothing but ignored
+// columns changed.
+func auditDiff(before, after map[string]interface{}) string {
+	changes := map[string]models.AuditChange{}
+	significant := false
+	for _, columns := range []map[string]interface{}{before, after} {
+		for column := range columns {
+			if _, seen := changes[column]; seen {
+				continue
+			}
+			oldValue, newValue := normalizeAuditValue(before[column]), normalizeAuditValue(after[column])
+			if before != nil && after != nil && fmt.Sprint(oldValue) == fmt.Sprint(newValue) {
+				continue
+			}
+			if auditRedacted[column] {
+				oldValue, newValue = redact(oldValue), redact(newValue)
+			}
+			changes[column] = models.AuditChange{Old: oldValue, New: newValue}
+			if !auditIgnored[column] {
+				significant = true
+			}
+		}
+	}
+	if !significant {
+		return ""
+	}
+	data, _ := json.Marshal(changes)
+	return string(data)
+}
+
+func redact(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return "[redacted]"
+}
+
+func normalizeAuditValue(value interface{}) interface{} {
+	if data, ok := value.([]byte); ok {
+		return string(data)
+	}
+	return value
+}
+
+func auditEntry(db *gorm.DB, action string, row map[string]interface{}, changes string) models.AuditLog {
+	entry := models.AuditLog{ActorType: models.ActorSystem, Action: action, Entity: db.Statement.Table, Changes: changes}
+	if info, ok := db.Statement.Context.Value(auditContextKey{}).(*AuditInfo); ok {
+		entry.ActorType, entry.ActorID, entry.APIKeyID = info.ActorType, info.ActorID, info.APIKeyID
+		entry.IP, entry.UserAgent, entry.RequestID = info.IP, info.UserAgent, info.RequestID
+	}
+
+	if pk := primaryKey(db); pk != "" {
+		entry.EntityID = fmt.Sprint(normalizeAuditValue(row[pk]))
+	} else {
+		var parts []string
+		for _, name := range db.Statement.Schema.PrimaryFieldDBNames {
+			parts = append(parts, fmt.Sprint(normalizeAuditValue(row[name])))
+		}
+		entry.EntityID = strings.Join(parts, ",")
+	}
+	return entry
+}
+
+func writeAudit(db *gorm.DB, entries []models.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+	// same connection, so the entries commit or roll back with the write
+	if err := db.Session(&gorm.Session{NewDB: true}).CreateInBatches(&entries, auditBatchSize).Error; err != nil {
+		db.AddError(err)
+	}
+}
+
+func primaryKey(db *gorm.DB) string {
+	if field := db.Statement.Schema.PrioritizedPrimaryField; field != nil {
+		return field.DBName
+	}
+	return ""
+}
+
+func forEachRecord(value reflect.Value, fn func(reflect.Value)) {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fn(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+// AuditFilter narrows an audit log search. Zero values are ignored.
+type AuditFilter struct {
+	ActorType string
+	ActorID   uint
+	Entity    string
+	EntityID  string
+	Action    string
+	RequestID string
+	From      time.Time
+	To        time.Time
+}
+
+// FilterAuditLogs applies the filter to a query on audit_logs, newest
+// first.
+func FilterAuditLogs(db *gorm.DB, filter AuditFilter) *gorm.DB {
+	query := db.Model(&models.AuditLog{})
+	if filter.ActorType != "" {
+		query = query.Where("actor_type = ?", filter.ActorType)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query
+}
+
+// AuditCSVHeader is the header row of the audit export.
+var AuditCSVHeader = []string{"id", "created_at", "actor_type", "actor_id", "api_key_id", "action", "entity", "entity_id", "changes", "ip", "user_agent", "request_id"}
+
+// AuditCSVRow formats an entry for the audit export, with every cell passed
+// through CSVSafe.
+func AuditCSVRow(entry models.AuditLog) []string {
+	optional := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return fmt.Sprint(*id)
+	}
+	row := []string{
+		fmt.Sprint(entry.ID), entry.CreatedAt.Format(time.RFC3339), entry.ActorType, optional(entry.ActorID),
+		optional(entry.APIKeyID), entry.Action, entry.Entity, entry.EntityID, entry.Changes,
+		entry.IP, entry.UserAgent, entry.RequestID,
+	}
+	for i, cell := range row {
+		row[i] = CSVSafe(cell)
+	}
+	return row
+}
+
+// CSVSafe keeps spreadsheets from running a cell as a formula: cells that
+// start with =, +, - or @ (or a tab or carriage return, which some
+// spreadsheets skip) get a leading apostrophe.
+func CSVSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"attendance/models"
+)
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"jhon", "jhon"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1", "'+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+		{`{"fullname":{"new":"=1"}}`, `{"fullname":{"new":"=1"}}`},
+	}
+	for _, test := range tests {
+		if got := CSVSafe(test.cell); got != test.want {
+			t.Errorf("CSVSafe(%q) = %q, want %q", test.cell, got, test.want)
+		}
+	}
+}
+
+func TestAuditCSVRowEscapesCells(t *testing.T) {
+	actor := uint(3)
+	row := AuditCSVRow(models.AuditLog{
+		ID:        1,
+		CreatedAt: time.Date(2023, 5, 2, 9, 0, 0, 0, time.UTC),
+		ActorType: models.ActorEmployee,
+		ActorID:   &actor,
+		Action:    models.AuditUpdate,
+		Entity:    "employees",
+		EntityID:  "7",
+		Changes:   `{"fullname":{"old":"a","new":"b"}}`,
+		IP:        "10.0.0.1",
+		UserAgent: "=cmd|' /C calc'!A0",
+		RequestID: "@abc",
+	})
+	if len(row) != len(AuditCSVHeader) {
+		t.Fatalf("row has %d cells, header %d", len(row), len(AuditCSVHeader))
+	}
+	if row[3] != "3" || row[4] != "" {
+		t.Errorf("actor cells %q %q", row[3], row[4])
+	}
+	if row[10] != "'=cmd|' /C calc'!A0" || row[11] != "'@abc" {
+		t.Errorf("user agent %q and request ID %q are not escaped", row[10], row[11])
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]interface{}
+		after  map[string]interface{}
+		want   string
+	}{
+		{"create", nil, map[string]interface{}{"id": 1, "name": "HR"}, `{"id":{"new":1},"name":{"new":"HR"}}`},
+		{"delete", map[string]interface{}{"id": 1}, nil, `{"id":{"old":1}}`},
+		{"update", map[string]interface{}{"id": 1, "name": "HR"}, map[string]interface{}{"id": 1, "name": "People"}, `{"name":{"old":"HR","new":"People"}}`},
+		{"unchanged", map[string]interface{}{"id": 1}, map[string]interface{}{"id": 1}, ""},
+		{"only ignored columns", map[string]interface{}{"updated_at": "a"}, map[string]interface{}{"updated_at": "b"}, ""},
+		{"redacted", map[string]interface{}{"password": "x"}, map[string]interface{}{"password": "y"}, `{"password":{"old":"[redacted]","new":"[redacted]"}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := auditDiff(test.before, test.after); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
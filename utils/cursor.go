@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"attendance/apperror"
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for cursors that were tampered with or were
+// issued by another listing.
+var ErrInvalidCursor = apperror.ErrInvalidCursor
+
+// Cursor is a position in a list ordered newest first: the timestamp and
+// ID of the last row of the previous page.
+type Cursor struct {
+	Time time.Time `json:"t"`
+	ID   uint      `json:"id"`
+}
+
+type cursorPayload struct {
+	Scope string `json:"s"`
+	Cursor
+}
+
+// cursorSecretMinLength is the shortest CURSOR_SECRET accepted.
+const cursorSecretMinLength = 32
+
+// CheckCursorSecret reports whether CURSOR_SECRET is usable. Cursors are
+// signed with it, so every instance must share it and keep it across
+// restarts; the server refuses to start without it.
+func CheckCursorSecret() error {
+	if secret := envOr("CURSOR_SECRET", ""); len(secret) < cursorSecretMinLength {
+		return fmt.Errorf("CURSOR_SECRET must be set to at least %d characters", cursorSecretMinLength)
+	}
+	return nil
+}
+
+// cursorSecret signs cursors. It comes from CURSOR_SECRET, checked at
+// startup with CheckCursorSecret.
+func cursorSecret() []byte {
+	return []byte(envOr("CURSOR_SECRET", ""))
+}
+
+// EncodeCursor returns the opaque, signed form of a cursor. The scope names
+// the listing so a cursor cannot be replayed against another one.
+func EncodeCursor(scope string, cursor Cursor) string {
+	payload, _ := json.Marshal(cursorPayload{Scope: scope, Cursor: cursor})
+	mac := hmac.New(sha256.New, cursorSecret())
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DecodeCursor checks the signature and scope of a cursor from EncodeCursor.
+func DecodeCursor(scope, token string) (Cursor, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	mac := hmac.New(sha256.New, cursorSecret())
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var decoded cursorPayload
+	if err := json.Unmarshal(payload, &decoded); err != nil || decoded.Scope != scope {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return decoded.Cursor, nil
+}
+
+// ParseCursor reads the cursor and limit query parameters of a cursor
+// paginated listing. A missing cursor starts at the newest row; limit
+// defaults to DefaultPageLimit and may be at most MaxPageLimit.
+func ParseCursor(c echo.Context, scope string) (*Cursor, int, error) {
+	limit := DefaultPageLimit
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > MaxPageLimit {
+			return nil, 0, fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+		}
+	}
+	token := c.QueryParam("cursor")
+	if token == "" {
+		return nil, limit, nil
+	}
+	cursor, err := DecodeCursor(scope, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &cursor, limit, nil
+}
+
+// CursorQuery orders query newest first by timeColumn and idColumn, starts
+// after the cursor and fetches one row more than limit, which tells
+// CursorPage whether there is a next page. Rows inserted while a client
+// pages through the list never shift the pages. Paged tables carry a
+// composite index on the two columns to serve this.
+func CursorQuery(query *gorm.DB, timeColumn, idColumn string, after *Cursor, limit int) *gorm.DB {
+	if after != nil {
+		query = query.Where(fmt.Sprintf("(%s < ? OR (%s = ? AND %s < ?))", timeColumn, timeColumn, idColumn),
+			after.Time, after.Time, after.ID)
+	}
+	return query.Order(timeColumn + " DESC").Order(idColumn + " DESC").Limit(limit + 1)
+}
+
+// CursorPage trims a result of CursorQuery to limit rows and describes the
+// page. cursorAt returns the cursor of the i-th row.
+func CursorPage(scope string, limit, rows int, cursorAt func(i int) Cursor) (int, models.CursorPagination) {
+	page := models.CursorPagination{Limit: limit}
+	if rows <= limit {
+		return rows, page
+	}
+	next := EncodeCursor(scope, cursorAt(limit-1))
+	page.NextCursor = &next
+	return limit, page
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckCursorSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		valid  bool
+	}{
+		{"unset", "", false},
+		{"blank", "   ", false},
+		{"too short", strings.Repeat("a", 31), false},
+		{"long enough", strings.Repeat("a", 32), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CURSOR_SECRET", test.secret)
+			if err := CheckCursorSecret(); (err == nil) != test.valid {
+				t.Errorf("got %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", strings.Repeat("s", 32))
+	cursor := Cursor{Time: time.Date(2023, 5, 1, 9, 30, 0, 0, time.UTC), ID: 42}
+	token := EncodeCursor("notifications", cursor)
+	payload, signature, _ := strings.Cut(token, ".")
+	forged, _ := base64.RawURLEncoding.DecodeString(payload)
+	forged = []byte(strings.Replace(string(forged), `"id":42`, `"id":41`, 1))
+
+	tests := []struct {
+		name  string
+		scope string
+		token string
+		valid bool
+	}{
+		{"round trip", "notifications", token, true},
+		{"other listing", "audit-logs", token, false},
+		{"changed payload", "notifications", base64.RawURLEncoding.EncodeToString(forged) + "." + signature, false},
+		{"changed signature", "notifications", payload + "." + strings.Repeat("A", len(signature)), false},
+		{"no signature", "notifications", payload, false},
+		{"not base64", "notifications", "!!." + signature, false},
+		{"empty", "notifications", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DecodeCursor(test.scope, test.token)
+			if !test.valid {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("got %v, want %v", err, ErrInvalidCursor)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Time.Equal(cursor.Time) || got.ID != cursor.ID {
+				t.Errorf("got %+v, want %+v", got, cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorOtherSecret(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", strings.Repeat("s", 32))
+	token := EncodeCursor("notifications", Cursor{ID: 1})
+	t.Setenv("CURSOR_SECRET", strings.Repeat("t", 32))
+	if _, err := DecodeCursor("notifications", token); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("got %v, want %v", err, ErrInvalidCursor)
+	}
+}
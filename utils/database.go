@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
-	"gorm.io/driver/mysql"
+	"github.com/go-sql-driver/mysql"
+
+	gormmysql "gorm.io/driver/mysql"
 	"gorm.io/gorm"
 
 	"github.com/joho/godotenv"
@@ -25,5 +28,18 @@ func Connect() (*gorm.DB, error) {
 
 	dsn := dbUser + ":" + dbPassword + "@tcp(" + dbHost + ":" + dbPort + ")/" + dbName + "?charset=utf8mb4&parseTime=True&loc=Local"
 
-	return gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(gormmysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := registerAuditCallbacks(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// IsDuplicateKey reports whether err is a MySQL unique key violation.
+func IsDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
 }
@@ -0,0 +1,427 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrImportInvalid is returned with the report when at least one row is
+// invalid. Nothing is imported in that case.
+var ErrImportInvalid = errors.New("the file has invalid rows, nothing was imported")
+
+// ErrImportFile is returned for files that cannot be read as an employee
+// import at all, such as malformed CSV or unknown columns.
+var ErrImportFile = errors.New("Invalid import file")
+
+// EmployeeImportColumns are the columns of an employee import file. Only
+// username, fullname and email are required; department and team are
+// names, manager is the username of an existing or imported employee.
+var EmployeeImportColumns = []string{"username", "fullname", "email", "phone_number", "address", "location", "hire_date", "department", "team", "manager"}
+
+var employeeImportRequired = []string{"username", "fullname", "email"}
+
+// employeeImportMaxRows caps the size of a single import.
+const employeeImportMaxRows = 5000
+
+// WriteEmployeeImportTemplate writes the header of an import file followed
+// by an example row.
+func WriteEmployeeImportTemplate(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write(EmployeeImportColumns)
+	writer.Write([]string{"jdoe", "John Doe", "jdoe@example.com", "+62 812 0000 0000", "Jl. Sudirman 1, Jakarta", "Jakarta", "2023-05-01", "Engineering", "Platform", "asmith"})
+	writer.Flush()
+	return writer.Error()
+}
+
+type importRow struct {
+	line         int
+	values       map[string]string
+	hireDate     *time.Time
+	departmentID *uint
+	teamID       *uint
+	existing     *models.Employee
+	passwordHash string
+}
+
+// ImportEmployees creates the employees of a CSV file that do not exist yet
+// and updates those that do, matching on username. Empty cells leave the
+// current value of an existing employee untouched. Every row is validated
+// before anything is written and the whole file is imported in one
+// transaction. New employees get an invitation email to choose their
+// password instead of a password in the file.
+func ImportEmployees(db *gorm.DB, r io.Reader, dryRun bool) (models.EmployeeImportReport, error) {
+	report := models.EmployeeImportReport{DryRun: dryRun, Rows: []models.EmployeeImportRow{}}
+
+	rows, err := readEmployeeImport(r)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrImportFile, err)
+	}
+
+	var employees []models.Employee
+	if err := db.Select("id", "username", "email", "manager_id").Find(&employees).Error; err != nil {
+		return report, err
+	}
+	var departments []models.Department
+	if err := db.Find(&departments).Error; err != nil {
+		return report, err
+	}
+	var teams []models.Team
+	if err := db.Find(&teams).Error; err != nil {
+		return report, err
+	}
+
+	report = validateEmployeeImport(rows, employees, departments, teams)
+	report.DryRun = dryRun
+	if report.Invalid > 0 {
+		return report, ErrImportInvalid
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	// bcrypt is slow on purpose, so hash before the transaction instead of
+	// holding it open for every new employee
+	if err := hashImportPasswords(rows); err != nil {
+		return report, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return applyEmployeeImport(tx, rows, employees)
+	})
+	return report, err
+}
+
+// validateEmployeeImport checks every row against the current employees,
+// departments and teams and links rows to the employee they update.
+func validateEmployeeImport(rows []*importRow, employees []models.Employee, departments []models.Department, teams []models.Team) models.EmployeeImportReport {
+	report := models.EmployeeImportReport{Rows: []models.EmployeeImportRow{}}
+
+	byUsername := map[string]*models.Employee{}
+	byEmail := map[string]*models.Employee{}
+	byID := map[uint]*models.Employee{}
+	for i := range employees {
+		byUsername[strings.ToLower(employees[i].Username)] = &employees[i]
+		byEmail[strings.ToLower(employees[i].Email)] = &employees[i]
+		byID[employees[i].ID] = &employees[i]
+	}
+
+	// the manager of every employee after the import, by lower-case username
+	managerOf := map[string]string{}
+	for _, employee := range employees {
+		if employee.ManagerID != nil && byID[*employee.ManagerID] != nil {
+			managerOf[strings.ToLower(employee.Username)] = strings.ToLower(byID[*employee.ManagerID].Username)
+		}
+	}
+	inFile := map[string]bool{}
+	for _, row := range rows {
+		if manager := row.values["manager"]; manager != "" {
+			managerOf[strings.ToLower(row.values["username"])] = strings.ToLower(manager)
+		}
+		inFile[strings.ToLower(row.values["username"])] = true
+	}
+
+	seenUsernames := map[string]int{}
+	seenEmails := map[string]int{}
+	for _, row := range rows {
+		username := row.values["username"]
+		result := models.EmployeeImportRow{Row: row.line, Username: username, Action: models.ImportCreate}
+		problem := func(format string, args ...interface{}) {
+			result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+		}
+
+		key := strings.ToLower(username)
+		row.existing = byUsername[key]
+		if row.existing != nil {
+			result.Action = models.ImportUpdate
+		}
+
+		for _, column := range employeeImportRequired {
+			if row.values[column] == "" && (row.existing == nil || column == "username") {
+				problem("%s is required", column)
+			}
+		}
+		if username != "" {
+			if first, ok := seenUsernames[key]; ok {
+				problem("username %s already appears in row %d", username, first)
+			} else {
+				seenUsernames[key] = row.line
+			}
+		}
+
+		if email := row.values["email"]; email != "" {
+			emailKey := strings.ToLower(email)
+			if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+				problem("email %s is not a valid address", email)
+			}
+			if first, ok := seenEmails[emailKey]; ok {
+				problem("email %s already appears in row %d", email, first)
+			} else {
+				seenEmails[emailKey] = row.line
+			}
+			if owner := byEmail[emailKey]; owner != nil && owner != row.existing {
+				problem("email %s is already used by %s", email, owner.Username)
+			}
+		}
+
+		if value := row.values["hire_date"]; value != "" {
+			if date, err := ParseDate(value); err != nil {
+				problem("hire_date %s is not a YYYY-MM-DD date", value)
+			} else {
+				row.hireDate = &date
+			}
+		}
+
+		if name := row.values["department"]; name != "" {
+			var matches []uint
+			for _, department := range departments {
+				if strings.EqualFold(department.Name, name) {
+					matches = append(matches, department.ID)
+				}
+			}
+			switch len(matches) {
+			case 0:
+				problem("department %s does not exist", name)
+			case 1:
+				row.departmentID = &matches[0]
+			default:
+				problem("department name %s is ambiguous", name)
+			}
+		}
+		if name := row.values["team"]; name != "" {
+			var matches []models.Team
+			for _, team := range teams {
+				if strings.EqualFold(team.Name, name) && (row.departmentID == nil || team.DepartmentID == *row.departmentID) {
+					matches = append(matches, team)
+				}
+			}
+			switch len(matches) {
+			case 0:
+				problem("team %s does not exist in that department", name)
+			case 1:
+				row.teamID = &matches[0].ID
+				if row.departmentID == nil {
+					row.departmentID = &matches[0].DepartmentID
+				}
+			default:
+				problem("team name %s is ambiguous, give its department", name)
+			}
+		}
+
+		if manager := strings.ToLower(row.values["manager"]); manager != "" {
+			switch {
+			case manager == key:
+				problem("an employee cannot be their own manager")
+			case byUsername[manager] == nil && !inFile[manager]:
+				problem("manager %s does not exist", row.values["manager"])
+			default:
+				// walk up the reporting line; meeting this employee again is a cycle
+				for current, steps := managerOf[manager], 0; current != "" && steps <= len(managerOf); current, steps = managerOf[current], steps+1 {
+					if current == key {
+						problem("manager %s reports to this employee", row.values["manager"])
+						break
+					}
+				}
+			}
+		}
+
+		if len(result.Errors) > 0 {
+			report.Invalid++
+		} else if row.existing == nil {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	return report
+}
+
+func readEmployeeImport(r io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, column := range EmployeeImportColumns {
+		known[column] = true
+	}
+	present := map[string]bool{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !known[column] {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		if present[column] {
+			return nil, fmt.Errorf("duplicate column %q", column)
+		}
+		present[column] = true
+		header[i] = column
+	}
+	for _, column := range employeeImportRequired {
+		if !present[column] {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	var rows []*importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) == employeeImportMaxRows {
+			return nil, fmt.Errorf("the file has more than %d rows", employeeImportMaxRows)
+		}
+		row := &importRow{line: line, values: map[string]string{}}
+		for i, value := range record {
+			row.values[header[i]] = strings.TrimSpace(value)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the file has no employees")
+	}
+	return rows, nil
+}
+
+// applyEmployeeImport writes validated rows. Managers are set in a second
+// pass so rows may refer to employees further down the file.
+func applyEmployeeImport(tx *gorm.DB, rows []*importRow, employees []models.Employee) error {
+	ids := map[string]uint{}
+	for _, employee := range employees {
+		ids[strings.ToLower(employee.Username)] = employee.ID
+	}
+
+	for _, row := range rows {
+		key := strings.ToLower(row.values["username"])
+		if row.existing != nil {
+			updates := map[string]interface{}{}
+			for column, value := range row.values {
+				switch column {
+				case "fullname", "email", "phone_number", "address", "location":
+					if value != "" {
+						updates[column] = value
+					}
+				}
+			}
+			if row.hireDate != nil {
+				updates["hire_date"] = *row.hireDate
+			}
+			if row.departmentID != nil {
+				updates["department_id"] = *row.departmentID
+			}
+			if row.teamID != nil {
+				updates["team_id"] = *row.teamID
+			}
+			if len(updates) > 0 {
+				updates["version"] = gorm.Expr("version + 1")
+				if err := tx.Model(row.existing).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		employee, err := createImportedEmployee(tx, row)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", row.line, err)
+		}
+		ids[key] = employee.ID
+	}
+
+	for _, row := range rows {
+		if manager := row.values["manager"]; manager != "" {
+			managerID := ids[strings.ToLower(manager)]
+			if err := tx.Model(&models.Employee{}).Where("id = ?", ids[strings.ToLower(row.values["username"])]).
+				Updates(map[string]interface{}{"manager_id": managerID, "version": gorm.Expr("version + 1")}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hashImportPasswords gives every new employee a random password hash.
+// Nobody knows the password; the invitation is the way in.
+func hashImportPasswords(rows []*importRow) error {
+	for _, row := range rows {
+		if row.existing != nil {
+			continue
+		}
+		password, err := RandomToken(32)
+		if err != nil {
+			return err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		row.passwordHash = string(hash)
+	}
+	return nil
+}
+
+func createImportedEmployee(tx *gorm.DB, row *importRow) (models.Employee, error) {
+	employee := models.Employee{
+		Username:     row.values["username"],
+		Fullname:     row.values["fullname"],
+		Email:        row.values["email"],
+		Password:     row.passwordHash,
+		PhoneNumber:  row.values["phone_number"],
+		Address:      row.values["address"],
+		Location:     row.values["location"],
+		Role:         "user",
+		Status:       models.EmployeeActive,
+		HireDate:     row.hireDate,
+		DepartmentID: row.departmentID,
+		TeamID:       row.teamID,
+	}
+	if err := tx.Omit(clause.Associations).Create(&employee).Error; err != nil {
+		return employee, err
+	}
+	if err := AssignRoles(tx, &employee, []string{models.RoleEmployee}); err != nil {
+		return employee, err
+	}
+
+	token, err := IssueInvitation(tx, employee.ID)
+	if err != nil {
+		return employee, err
+	}
+	if err := Notify(tx, models.EventInvitation, employee.Email, map[string]interface{}{
+		"Fullname":  employee.Fullname,
+		"Username":  employee.Username,
+		"Link":      PasswordResetLink(token),
+		"ExpiresIn": InvitationTTL().String(),
+	}); err != nil {
+		return employee, err
+	}
+	PublishEvent(tx, models.EventEmployeeCreated, models.EmployeeEventData{
+		ID:       employee.ID,
+		Username: employee.Username,
+		Fullname: employee.Fullname,
+		Email:    employee.Email,
+		Status:   employee.Status,
+	})
+	return employee, nil
+}
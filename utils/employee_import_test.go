@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"attendance/models"
+)
+
+func TestReadEmployeeImport(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		valid bool
+	}{
+		{"minimal", "username,fullname,email\njdoe,John Doe,jdoe@example.com\n", true},
+		{"byte order mark and case", "\ufeffUsername, Fullname ,EMAIL\njdoe,John Doe,jdoe@example.com\n", true},
+		{"empty", "", false},
+		{"header only", "username,fullname,email\n", false},
+		{"unknown column", "username,fullname,email,role\njdoe,John Doe,jdoe@example.com,admin\n", false},
+		{"duplicate column", "username,fullname,email,email\njdoe,John Doe,jdoe@example.com,x@example.com\n", false},
+		{"missing column", "username,fullname\njdoe,John Doe\n", false},
+		{"ragged row", "username,fullname,email\njdoe,John Doe\n", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readEmployeeImport(strings.NewReader(test.file))
+			if (err == nil) != test.valid {
+				t.Errorf("got %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestValidateEmployeeImport(t *testing.T) {
+	managerID := uint(1)
+	employees := []models.Employee{
+		{Model: models.Model{ID: 1}, Username: "asmith", Email: "asmith@example.com"},
+		{Model: models.Model{ID: 2}, Username: "bjones", Email: "bjones@example.com", ManagerID: &managerID},
+	}
+	departments := []models.Department{
+		{Model: models.Model{ID: 1}, Name: "Engineering"},
+		{Model: models.Model{ID: 2}, Name: "Sales"},
+		{Model: models.Model{ID: 3}, Name: "sales"},
+	}
+	teams := []models.Team{
+		{Model: models.Model{ID: 1}, Name: "Platform", DepartmentID: 1},
+		{Model: models.Model{ID: 2}, Name: "Field", DepartmentID: 2},
+		{Model: models.Model{ID: 3}, Name: "Field", DepartmentID: 3},
+	}
+	header := "username,fullname,email,hire_date,department,team,manager\n"
+
+	tests := []struct {
+		name    string
+		rows    string
+		action  string
+		problem string
+	}{
+		{name: "new employee", rows: "jdoe,John Doe,jdoe@example.com,2023-05-01,Engineering,Platform,asmith", action: models.ImportCreate},
+		{name: "update keeps empty cells", rows: "BJones,,,,,,", action: models.ImportUpdate},
+		{name: "team sets the department", rows: "jdoe,John Doe,jdoe@example.com,,,platform,", action: models.ImportCreate},
+		{name: "manager later in the file", rows: "jdoe,John Doe,jdoe@example.com,,,,mlee\nmlee,Mary Lee,mlee@example.com,,,,", action: models.ImportCreate},
+		{name: "missing fullname", rows: "jdoe,,jdoe@example.com,,,,", action: models.ImportCreate, problem: "fullname is required"},
+		{name: "invalid email", rows: "jdoe,John Doe,John <jdoe@example.com>,,,,", action: models.ImportCreate, problem: "is not a valid address"},
+		{name: "email of another employee", rows: "jdoe,John Doe,ASmith@example.com,,,,", action: models.ImportCreate, problem: "is already used by asmith"},
+		{name: "duplicate username", rows: "jdoe,John Doe,jdoe@example.com,,,,\nJDoe,John Doe,john@example.com,,,,", action: models.ImportCreate, problem: "already appears in row 2"},
+		{name: "bad hire date", rows: "jdoe,John Doe,jdoe@example.com,01/05/2023,,,", action: models.ImportCreate, problem: "is not a YYYY-MM-DD date"},
+		{name: "unknown department", rows: "jdoe,John Doe,jdoe@example.com,,Finance,,", action: models.ImportCreate, problem: "department Finance does not exist"},
+		{name: "ambiguous department", rows: "jdoe,John Doe,jdoe@example.com,,Sales,,", action: models.ImportCreate, problem: "is ambiguous"},
+		{name: "team outside the department", rows: "jdoe,John Doe,jdoe@example.com,,Engineering,Field,", action: models.ImportCreate, problem: "does not exist in that department"},
+		{name: "ambiguous team", rows: "jdoe,John Doe,jdoe@example.com,,,Field,", action: models.ImportCreate, problem: "give its department"},
+		{name: "own manager", rows: "jdoe,John Doe,jdoe@example.com,,,,jdoe", action: models.ImportCreate, problem: "their own manager"},
+		{name: "unknown manager", rows: "jdoe,John Doe,jdoe@example.com,,,,nobody", action: models.ImportCreate, problem: "manager nobody does not exist"},
+		{name: "reporting cycle", rows: "asmith,,,,,,bjones", action: models.ImportUpdate, problem: "reports to this employee"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := readEmployeeImport(strings.NewReader(header + test.rows + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			report := validateEmployeeImport(rows, employees, departments, teams)
+			// the row under test is the last one of the file
+			result := report.Rows[len(report.Rows)-1]
+			if result.Action != test.action {
+				t.Errorf("action %q, want %q", result.Action, test.action)
+			}
+			if test.problem == "" {
+				if report.Invalid != 0 {
+					t.Errorf("unexpected errors %v", report.Rows)
+				}
+				return
+			}
+			if report.Invalid == 0 || len(result.Errors) == 0 || !strings.Contains(strings.Join(result.Errors, "; "), test.problem) {
+				t.Errorf("errors %v, want one containing %q", result.Errors, test.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrEmployeeInactive is returned when a suspended or terminated employee
+// tries to log in or clock in.
+var ErrEmployeeInactive = errors.New("employee account is not active")
+
+// ErrInvalidTerminationDate is returned for termination dates before the
+// hire date.
+var ErrInvalidTerminationDate = errors.New("termination date is before the hire date")
+
+// WorkingEmployees narrows an employee query to those who may work on day:
+// active employees and terminated ones whose termination date is not past.
+func WorkingEmployees(db *gorm.DB, day time.Time) *gorm.DB {
+	return db.Where("(status = ? OR (status = ? AND termination_date >= ?))",
+		models.EmployeeActive, models.EmployeeTerminated, StartOfDay(day))
+}
+
+// EmployedBetween narrows an employee query to those employed at some point
+// between from and to, both inclusive. Missing hire and termination dates
+// do not exclude anyone.
+func EmployedBetween(db *gorm.DB, from, to time.Time) *gorm.DB {
+	return db.Where("(hire_date IS NULL OR hire_date <= ?) AND (termination_date IS NULL OR termination_date >= ?)",
+		StartOfDay(to), StartOfDay(from))
+}
+
+// TerminateEmployee ends the employment on date, the last working day.
+// When that day is already over the employee is logged out everywhere;
+// otherwise their tokens stop working once it passes.
+func TerminateEmployee(db *gorm.DB, employee *models.Employee, date time.Time) error {
+	date = StartOfDay(date)
+	if employee.HireDate != nil && date.Before(StartOfDay(*employee.HireDate)) {
+		return ErrInvalidTerminationDate
+	}
+	return setEmploymentStatus(db, employee, models.EmployeeTerminated, &date)
+}
+
+// SuspendEmployee blocks the employee immediately without ending the
+// employment.
+func SuspendEmployee(db *gorm.DB, employee *models.Employee) error {
+	return setEmploymentStatus(db, employee, models.EmployeeSuspended, nil)
+}
+
+// RestoreEmployee reactivates a suspended employee or undoes a termination.
+func RestoreEmployee(db *gorm.DB, employee *models.Employee) error {
+	return setEmploymentStatus(db, employee, models.EmployeeActive, nil)
+}
+
+func setEmploymentStatus(db *gorm.DB, employee *models.Employee, status string, terminationDate *time.Time) error {
+	employee.Status = status
+	employee.TerminationDate = terminationDate
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := SaveEmployee(tx, employee, "status", "termination_date"); err != nil {
+			return err
+		}
+		if employee.CanWork(time.Now()) {
+			return nil
+		}
+		return RevokeSessions(tx, employee.ID)
+	})
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"attendance/apperror"
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
+)
+
+// ETag is the entity tag of a record at the given version.
+func ETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// SetETag sends the entity tag of a record at the given version.
+func SetETag(c echo.Context, version uint) {
+	c.Response().Header().Set(HeaderETag, ETag(version))
+}
+
+// CheckIfMatch answers requests whose If-Match header names none of the
+// current version's tag with apperror.ErrVersionConflict, and requests
+// without the header with apperror.ErrPreconditionRequired, so a client
+// cannot overwrite a change it has not seen by leaving the header out.
+func CheckIfMatch(c echo.Context, version uint) error {
+	header := c.Request().Header.Get(HeaderIfMatch)
+	if header == "" {
+		return apperror.ErrPreconditionRequired
+	}
+	current := ETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// weak tags never match, as If-Match compares strongly
+		if tag == "*" || tag == current {
+			return nil
+		}
+	}
+	return apperror.ErrVersionConflict
+}
+
+// SaveVersioned updates the given columns of record, a pointer to a model
+// with a Version column, and bumps version, which points at that column.
+// Nothing is written when the stored version is no longer the one loaded,
+// so a concurrent change is reported as apperror.ErrVersionConflict instead
+// of being overwritten.
+func SaveVersioned(db *gorm.DB, record interface{}, version *uint, columns ...string) error {
+	loaded := *version
+	*version++
+	result := db.Model(record).Where("version = ?", loaded).
+		Select(append(columns, "version")).Updates(record)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = apperror.ErrVersionConflict
+	}
+	if result.Error != nil {
+		*version = loaded
+	}
+	return result.Error
+}
+
+// SaveEmployee is SaveVersioned for employees.
+func SaveEmployee(db *gorm.DB, employee *models.Employee, columns ...string) error {
+	return SaveVersioned(db, employee, &employee.Version, columns...)
+}
+
+// DeleteVersioned deletes record, loaded at version, in one transaction
+// with the rows related removes first. Like SaveVersioned it reports
+// apperror.ErrVersionConflict, and deletes nothing, when the record changed
+// since it was loaded.
+func DeleteVersioned(db *gorm.DB, record interface{}, version uint, related func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// lock the record before touching anything that refers to it
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("version = ?", version).Take(record).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.ErrVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+		if related != nil {
+			if err := related(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(record).Error
+	})
+}
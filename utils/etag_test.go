@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"attendance/apperror"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    error
+	}{
+		{"missing", "", apperror.ErrPreconditionRequired},
+		{"current", `"3"`, nil},
+		{"any", "*", nil},
+		{"one of several", `"2", "3"`, nil},
+		{"stale", `"2"`, apperror.ErrVersionConflict},
+		{"weak", `W/"3"`, apperror.ErrVersionConflict},
+		{"unquoted", "3", apperror.ErrVersionConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/", nil)
+			if test.ifMatch != "" {
+				request.Header.Set(HeaderIfMatch, test.ifMatch)
+			}
+			c := echo.New().NewContext(request, httptest.NewRecorder())
+			if err := CheckIfMatch(c, 3); err != test.want {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
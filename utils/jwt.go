@@ -1,92 +1,164 @@
 package utils
 
 import (
-	"attendance/models"
+	"attendance/apperror"
 	"fmt"
-	"log"
-	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
-var JwtKey = []byte("secret_key23e4")
-
+// Claims are the claims of an access token.
 type Claims struct {
-	UserID int  `json:"user_id"`
-	Role   bool `json:"is_admin"`
+	UserID    int    `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid"`
 	jwt.StandardClaims
 }
 
-func GenerateToken(userID int, Role string) (string, error) {
-	// function body
-	info := jwt.MapClaims{}
-	info["ID"] = userID
-	info["role"] = Role
-	auth := jwt.NewWithClaims(jwt.SigningMethodHS256, info)
-	token, err := auth.SignedString(JwtKey)
+// Principal is the authenticated caller. AuthMiddleware stores it in the
+// context; handlers read it with CurrentPrincipal or CallerID. API key
+// callers have an APIKeyID and no EmployeeID.
+type Principal struct {
+	EmployeeID  int
+	Role        string
+	SessionID   uint
+	APIKeyID    uint
+	Permissions map[string]bool
+}
+
+// IsAPIKey reports whether the caller authenticated with an API key.
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != 0
+}
+
+const principalKey = "principal"
+
+// AccessTokenTTL is the lifetime of access tokens, configured in minutes
+// through ACCESS_TOKEN_MINUTES (default 15).
+func AccessTokenTTL() time.Duration {
+	return time.Duration(envInt("ACCESS_TOKEN_MINUTES", 15)) * time.Minute
+}
+
+// GenerateToken issues an access token for the session and returns it with
+// its expiry.
+func GenerateToken(userID int, Role string, sessionID uint) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(AccessTokenTTL())
+	claims := Claims{
+		UserID:    userID,
+		Role:      Role,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+	ring, err := Keys()
 	if err != nil {
-		log.Fatal("cannot generate key")
-		return "", nil
+		return "", time.Time{}, err
 	}
-	return token, err
+	token := jwt.NewWithClaims(ring.Active.Method(), claims)
+	token.Header["kid"] = ring.Active.ID
+	signed, err := token.SignedString(ring.Active.Private)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
 }
 
-func ExtractData(c echo.Context) (int, string, error) {
-	head := c.Request().Header.Get("Authorization")
-	if head == "" {
-		return -1, "", fmt.Errorf("Authorization header not provided")
+// ParseToken verifies an access token against the key named by its kid
+// header and returns its claims.
+func ParseToken(tokenString string) (*Claims, error) {
+	ring, err := Keys()
+	if err != nil {
+		return nil, err
 	}
 
-	token := strings.Split(head, " ")
-
-	res, err := jwt.Parse(token[len(token)-1], func(t *jwt.Token) (interface{}, error) {
-		return []byte(JwtKey), nil
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := ring.Keys[kid]
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Alg {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.VerificationKey(), nil
 	})
 	if err != nil {
-		return -1, "", err
+		return nil, err
 	}
 
-	if res.Valid {
-		resClaim := res.Claims.(jwt.MapClaims)
-		parseID := int(resClaim["ID"].(float64))
-		parseRole := resClaim["role"].(string)
-		return parseID, parseRole, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("Invalid token")
 	}
-
-	return -1, "", fmt.Errorf("Invalid token")
+	return claims, nil
 }
 
-func AuthMiddleware() echo.MiddlewareFunc {
+// AuthMiddleware authenticates the caller by API key or bearer token,
+// rejects revoked credentials and stores the caller with their permissions
+// in the context.
+func AuthMiddleware(db *gorm.DB) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			db := db.WithContext(c.Request().Context())
+
+			if key := c.Request().Header.Get(APIKeyHeader); key != "" {
+				principal, err := AuthenticateAPIKey(db, key, c.RealIP())
+				if err != nil {
+					return apperror.ErrInvalidAPIKey.Wrap(err)
+				}
+				c.Set(principalKey, principal)
+				setAuditActor(c, principal)
+				return next(c)
+			}
+
 			authHeader := c.Request().Header.Get("Authorization")
 			if !strings.HasPrefix(authHeader, "Bearer ") {
-				return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid Authorization header"})
+				return apperror.ErrUnauthorized.WithMessage("Invalid Authorization header")
 			}
 
-			tokenString := authHeader[7:]
-			token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(JwtKey), nil
-			})
-
+			claims, err := ParseToken(authHeader[7:])
+			if err != nil {
+				return apperror.ErrInvalidToken.Wrap(err)
+			}
+			if revoked, err := TokenRevoked(db, claims.UserID, claims.SessionID, time.Unix(claims.IssuedAt, 0)); err != nil || revoked {
+				return apperror.ErrTokenRevoked
+			}
+			granted, err := EmployeePermissions(db, uint(claims.UserID))
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+				return apperror.Internal(err)
 			}
 
-			if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-				c.Set("user_id", claims.UserID)
-				if !claims.Role {
-					return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "You do not have permission to access this resource"})
-				}
-				return next(c)
-			} else {
-				return c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid token"})
+			principal := &Principal{
+				EmployeeID:  claims.UserID,
+				Role:        claims.Role,
+				SessionID:   claims.SessionID,
+				Permissions: granted,
 			}
+			c.Set(principalKey, principal)
+			setAuditActor(c, principal)
+			return next(c)
 		}
 	}
 }
+
+// CurrentPrincipal returns the caller authenticated by AuthMiddleware, or
+// nil on public routes.
+func CurrentPrincipal(c echo.Context) *Principal {
+	principal, _ := c.Get(principalKey).(*Principal)
+	return principal
+}
+
+// CallerID returns the ID of the authenticated caller.
+func CallerID(c echo.Context) int {
+	if principal := CurrentPrincipal(c); principal != nil {
+		return principal.EmployeeID
+	}
+	return 0
+}
@@ -0,0 +1,274 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"attendance/models"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Signing keys live in JWT_KEYS_DIR (default "keys") as PKCS#8 PEM files
+// named <kid>.pem. The file "active" holds the kid of the key that signs
+// new tokens; every other key in the directory is still accepted for
+// verification and published in the JWKS, so tokens signed before a
+// rotation stay valid until they expire.
+
+const activeKeyFile = "active"
+
+// Supported signing algorithms.
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+// SigningKey is a private key of the key ring.
+type SigningKey struct {
+	ID        string
+	Alg       string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// KeyRing holds the active signing key and every key accepted for
+// verification, keyed by kid.
+type KeyRing struct {
+	Active *SigningKey
+	Keys   map[string]*SigningKey
+}
+
+// KeysDir is the directory the key ring is loaded from.
+func KeysDir() string {
+	return envOr("JWT_KEYS_DIR", "keys")
+}
+
+// Method returns the JWT signing method of the key.
+func (k *SigningKey) Method() jwt.SigningMethod {
+	if k.Alg == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return SigningMethodEdDSA
+}
+
+// VerificationKey returns the public key in the form jwt-go verifies with.
+func (k *SigningKey) VerificationKey() interface{} {
+	return k.Private.Public()
+}
+
+// JWK returns the public half of the key in JSON Web Key format.
+func (k *SigningKey) JWK() models.JWK {
+	jwk := models.JWK{Kid: k.ID, Use: "sig", Alg: k.Alg}
+	switch public := k.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// JWKS returns the public keys of the ring, sorted by kid.
+func (r *KeyRing) JWKS() models.JWKS {
+	set := models.JWKS{Keys: []models.JWK{}}
+	for _, key := range r.Sorted() {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+// Sorted returns the keys of the ring sorted by kid, which sorts them by
+// creation time for generated keys.
+func (r *KeyRing) Sorted() []*SigningKey {
+	keys := make([]*SigningKey, 0, len(r.Keys))
+	for _, key := range r.Keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// LoadKeyRing reads every key in dir. It fails when keys exist but none is
+// marked active.
+func LoadKeyRing(dir string) (*KeyRing, error) {
+	ring := &KeyRing{Keys: map[string]*SigningKey{}}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		key, err := readKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		ring.Keys[key.ID] = key
+	}
+	if len(ring.Keys) == 0 {
+		return ring, nil
+	}
+
+	active, err := os.ReadFile(filepath.Join(dir, activeKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("no active signing key in %s: %w", dir, err)
+	}
+	kid := strings.TrimSpace(string(active))
+	if ring.Active = ring.Keys[kid]; ring.Active == nil {
+		return nil, fmt.Errorf("active signing key %q not found in %s", kid, dir)
+	}
+	return ring, nil
+}
+
+func readKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: strings.TrimSuffix(filepath.Base(path), ".pem"), CreatedAt: info.ModTime()}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Alg, key.Private = AlgRS256, private
+	case ed25519.PrivateKey:
+		key.Alg, key.Private = AlgEdDSA, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// GenerateKey creates a new key in dir without activating it.
+func GenerateKey(dir, alg string) (*SigningKey, error) {
+	var private crypto.Signer
+	switch alg {
+	case AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use %s or %s", alg, AlgEdDSA, AlgRS256)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	kid := now.Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		return nil, err
+	}
+	return &SigningKey{ID: kid, Alg: alg, Private: private, CreatedAt: now}, nil
+}
+
+// ActivateKey makes kid the key that signs new tokens.
+func ActivateKey(dir, kid string) error {
+	if _, err := os.Stat(filepath.Join(dir, kid+".pem")); err != nil {
+		return fmt.Errorf("key %q not found in %s", kid, dir)
+	}
+	return os.WriteFile(filepath.Join(dir, activeKeyFile), []byte(kid+"\n"), 0600)
+}
+
+// RetireKey deletes a key so tokens signed with it are no longer accepted.
+// The active key cannot be retired.
+func RetireKey(dir, kid string) error {
+	active, _ := os.ReadFile(filepath.Join(dir, activeKeyFile))
+	if strings.TrimSpace(string(active)) == kid {
+		return fmt.Errorf("key %q is active, rotate before retiring it", kid)
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("key %q not found in %s", kid, dir)
+	}
+	return os.Remove(path)
+}
+
+var (
+	keyRingMu       sync.Mutex
+	keyRing         *KeyRing
+	keyRingLoadedAt time.Time
+)
+
+// Keys returns the key ring, reloading it from disk at most once a minute
+// so rotations made with the keys command are picked up without a restart.
+// When the directory holds no keys an Ed25519 key is generated and
+// activated.
+func Keys() (*KeyRing, error) {
+	keyRingMu.Lock()
+	defer keyRingMu.Unlock()
+
+	if keyRing != nil && time.Since(keyRingLoadedAt) < time.Minute {
+		return keyRing, nil
+	}
+
+	ring, err := LoadKeyRing(KeysDir())
+	if err == nil && ring.Active == nil {
+		var key *SigningKey
+		if key, err = GenerateKey(KeysDir(), AlgEdDSA); err == nil {
+			if err = ActivateKey(KeysDir(), key.ID); err == nil {
+				log.Printf("Generated JWT signing key %s in %s", key.ID, KeysDir())
+				ring, err = LoadKeyRing(KeysDir())
+			}
+		}
+	}
+	if err != nil {
+		if keyRing != nil {
+			log.Println("Error reloading JWT keys, keeping the previous ones:", err)
+			keyRingLoadedAt = time.Now()
+			return keyRing, nil
+		}
+		return nil, err
+	}
+
+	keyRing, keyRingLoadedAt = ring, time.Now()
+	return keyRing, nil
+}
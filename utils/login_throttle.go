@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Login throttling: every failed login, whether a wrong password or a wrong
+// two-factor or recovery code, counts against the username and the client
+// IP. From the second failure on, the next attempt has to wait an
+// exponentially growing delay; after LOGIN_MAX_FAILURES (default 5)
+// failures the username is locked for LOGIN_LOCKOUT_MINUTES (default 15).
+// IPs get the same treatment with the higher LOGIN_IP_MAX_FAILURES
+// (default 20), since one office can share an address. Failures older than
+// the lockout period are forgotten.
+
+// ThrottleError is returned when a login has to wait.
+type ThrottleError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *ThrottleError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, try again in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed logins, wait %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginLockout is how long a username or IP stays locked.
+func LoginLockout() time.Duration {
+	return time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+func throttleLimit(kind string) int {
+	if kind == models.ThrottleIP {
+		return envInt("LOGIN_IP_MAX_FAILURES", 20)
+	}
+	return envInt("LOGIN_MAX_FAILURES", 5)
+}
+
+// throttleDelay is the wait after the given number of consecutive failures:
+// nothing after the first, then 1s, 2s, 4s... capped at 32s.
+func throttleDelay(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	if failures > 7 {
+		failures = 7
+	}
+	return time.Duration(1<<(failures-2)) * time.Second
+}
+
+func throttleKeys(username, ip string) map[string]string {
+	return map[string]string{
+		models.ThrottleUsername: strings.ToLower(strings.TrimSpace(username)),
+		models.ThrottleIP:       ip,
+	}
+}
+
+// CheckLoginAllowed returns a *ThrottleError when the username or the IP
+// is locked out or still has to wait after its last failure.
+func CheckLoginAllowed(db *gorm.DB, username, ip string) error {
+	now := time.Now()
+	var wait *ThrottleError
+	for kind, key := range throttleKeys(username, ip) {
+		var throttle models.LoginThrottle
+		if err := db.Where("kind = ? AND throttle_key = ?", kind, key).First(&throttle).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return err
+		}
+
+		var candidate *ThrottleError
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			candidate = &ThrottleError{RetryAfter: throttle.LockedUntil.Sub(now), Locked: true}
+		} else if until := throttle.LastFailureAt.Add(throttleDelay(throttle.Failures)); throttle.LockedUntil == nil && until.After(now) {
+			candidate = &ThrottleError{RetryAfter: until.Sub(now)}
+		}
+		if candidate != nil && (wait == nil || candidate.RetryAfter > wait.RetryAfter) {
+			wait = candidate
+		}
+	}
+	if wait != nil {
+		return wait
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed login and reports whether it locked
+// the username.
+func RecordLoginFailure(db *gorm.DB, username, ip string) (bool, error) {
+	locked := false
+	for kind, key := range throttleKeys(username, ip) {
+		lockedNow, err := recordFailure(db, kind, key)
+		if err != nil {
+			return false, err
+		}
+		if kind == models.ThrottleUsername {
+			locked = lockedNow
+		}
+	}
+	return locked, nil
+}
+
+func recordFailure(db *gorm.DB, kind, key string) (bool, error) {
+	locked := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// create the row if needed, then lock it so concurrent failures on
+		// other instances are counted one after the other
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Kind: kind, Key: key}).Error; err != nil {
+			return err
+		}
+		var throttle models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("kind = ? AND throttle_key = ?", kind, key).First(&throttle).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		lockout := LoginLockout()
+		if (throttle.LockedUntil != nil && !throttle.LockedUntil.After(now)) || now.Sub(throttle.LastFailureAt) > lockout {
+			throttle.Failures = 0
+			throttle.LockedUntil = nil
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		if throttle.LockedUntil == nil && throttle.Failures >= throttleLimit(kind) {
+			until := now.Add(lockout)
+			throttle.LockedUntil = &until
+			locked = true
+		}
+		return tx.Save(&throttle).Error
+	})
+	return locked, err
+}
+
+// RecordLoginSuccess clears the failures of the username. IP failures are
+// kept, so one valid account cannot be used to reset them.
+func RecordLoginSuccess(db *gorm.DB, username string) error {
+	return UnlockAccount(db, username)
+}
+
+// UnlockAccount lifts a username lockout.
+func UnlockAccount(db *gorm.DB, username string) error {
+	return db.Where("kind = ? AND throttle_key = ?", models.ThrottleUsername, throttleKeys(username, "")[models.ThrottleUsername]).
+		Delete(&models.LoginThrottle{}).Error
+}
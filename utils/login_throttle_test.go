@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"attendance/models"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{4, 4 * time.Second},
+		{6, 16 * time.Second},
+		{7, 32 * time.Second},
+		{50, 32 * time.Second},
+	}
+	for _, test := range tests {
+		if got := throttleDelay(test.failures); got != test.want {
+			t.Errorf("throttleDelay(%d) = %s, want %s", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestThrottleLimit(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "")
+	t.Setenv("LOGIN_IP_MAX_FAILURES", "")
+	if got := throttleLimit(models.ThrottleUsername); got != 5 {
+		t.Errorf("username limit %d, want 5", got)
+	}
+	if got := throttleLimit(models.ThrottleIP); got != 20 {
+		t.Errorf("IP limit %d, want 20", got)
+	}
+
+	t.Setenv("LOGIN_MAX_FAILURES", "3")
+	if got := throttleLimit(models.ThrottleUsername); got != 3 {
+		t.Errorf("configured username limit %d, want 3", got)
+	}
+}
+
+func TestThrottleKeys(t *testing.T) {
+	keys := throttleKeys("  Jhon.Doe ", "10.0.0.1")
+	if keys[models.ThrottleUsername] != "jhon.doe" || keys[models.ThrottleIP] != "10.0.0.1" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail is a message handed to a MailTransport. HTML is optional.
+type Mail struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// MailTransport delivers a single message.
+type MailTransport interface {
+	Send(mail Mail) error
+}
+
+// SMTPTransport sends mail through an SMTP server with PLAIN auth.
+type SMTPTransport struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (t SMTPTransport) Send(mail Mail) error {
+	message, err := buildMessage(t.From, mail)
+	if err != nil {
+		return err
+	}
+	auth := smtp.PlainAuth("", t.Username, t.Password, t.Host)
+	return smtp.SendMail(t.Host+":"+t.Port, auth, t.From, []string{mail.To}, message)
+}
+
+// SpoolTransport writes every message as an .eml file into Dir, for local
+// development.
+type SpoolTransport struct {
+	Dir  string
+	From string
+}
+
+func (t SpoolTransport) Send(mail Mail) error {
+	message, err := buildMessage(t.From, mail)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), mail.To)
+	return os.WriteFile(filepath.Join(t.Dir, name), message, 0o644)
+}
+
+// MemoryTransport keeps messages in memory, for local development and
+// tests.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []Mail
+}
+
+func (t *MemoryTransport) Send(mail Mail) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, mail)
+	return nil
+}
+
+// Messages returns a copy of every message sent so far.
+func (t *MemoryTransport) Messages() []Mail {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Mail(nil), t.messages...)
+}
+
+var memoryTransport = &MemoryTransport{}
+
+// MailTransportFromEnv picks the transport named by MAIL_DRIVER: smtp
+// (default), spool or memory.
+func MailTransportFromEnv() MailTransport {
+	from := envOr("MAIL_FROM", "pesan.reski@gmail.com")
+	switch os.Getenv("MAIL_DRIVER") {
+	case "spool":
+		return SpoolTransport{Dir: envOr("MAIL_SPOOL_DIR", "mail_spool"), From: from}
+	case "memory":
+		return memoryTransport
+	}
+	return SMTPTransport{
+		Host:     envOr("SMTP_HOST", "smtp.gmail.com"),
+		Port:     envOr("SMTP_PORT", "587"),
+		Username: envOr("SMTP_USERNAME", from),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
+func buildMessage(from string, mail Mail) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\n", from, mail.To, mail.Subject)
+
+	if mail.HTML == "" {
+		fmt.Fprintf(&buf, "Content-Type: text/plain; charset=utf-8\r\n\r\n%s", mail.Text)
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", mail.Text},
+		{"text/html; charset=utf-8", mail.HTML},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+func envOr(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"attendance/models"
+)
+
+func TestMailTransportFromEnvMemory(t *testing.T) {
+	t.Setenv("MAIL_DRIVER", "memory")
+	transport, ok := MailTransportFromEnv().(*MemoryTransport)
+	if !ok {
+		t.Fatalf("MAIL_DRIVER=memory gave %T", MailTransportFromEnv())
+	}
+
+	before := len(transport.Messages())
+	mail := Mail{To: "jhon@gmail.com", Subject: "Hello", Text: "Hi"}
+	if err := transport.Send(mail); err != nil {
+		t.Fatal(err)
+	}
+	messages := transport.Messages()
+	if len(messages) != before+1 || messages[len(messages)-1] != mail {
+		t.Fatalf("got %+v, want %+v appended", messages, mail)
+	}
+
+	messages[len(messages)-1].To = "changed"
+	if transport.Messages()[before].To != mail.To {
+		t.Error("Messages does not return a copy")
+	}
+}
+
+func TestRenderMail(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		data    map[string]interface{}
+		subject string
+		body    string
+	}{
+		{
+			name:    "link in text",
+			event:   models.EventPasswordReset,
+			data:    map[string]interface{}{"Fullname": "Jhon Doe", "Link": "https://example.com/reset?token=abc", "ExpiresIn": "1h0m0s"},
+			subject: "Reset your password",
+			body:    "https://example.com/reset?token=abc",
+		},
+		{
+			name:    "html escaped",
+			event:   models.EventPasswordReset,
+			data:    map[string]interface{}{"Fullname": "<b>Jhon</b>", "Link": "https://example.com", "ExpiresIn": "1h0m0s"},
+			subject: "Reset your password",
+			body:    "Hi <b>Jhon</b>,",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := &MemoryTransport{}
+			mail, err := RenderMail(test.event, "jhon@gmail.com", test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := transport.Send(mail); err != nil {
+				t.Fatal(err)
+			}
+
+			sent := transport.Messages()[0]
+			if sent.To != "jhon@gmail.com" || sent.Subject != test.subject {
+				t.Errorf("got to %q subject %q", sent.To, sent.Subject)
+			}
+			if !strings.Contains(sent.Text, test.body) {
+				t.Errorf("text %q does not contain %q", sent.Text, test.body)
+			}
+			if strings.Contains(sent.HTML, "<b>") {
+				t.Errorf("HTML body is not escaped: %q", sent.HTML)
+			}
+		})
+	}
+}
+
+func TestRenderMailUnknownEvent(t *testing.T) {
+	if _, err := RenderMail("no_such_event", "jhon@gmail.com", nil); err == nil {
+		t.Error("expected an error for an event without templates")
+	}
+}
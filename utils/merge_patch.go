@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"attendance/apperror"
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MergePatchContentType is the media type of JSON Merge Patch bodies.
+const MergePatchContentType = "application/merge-patch+json"
+
+// maxJSONBodySize caps the request bodies read by ReadJSONBody.
+const maxJSONBodySize = 1 << 20
+
+// ReadJSONBody reads the request body for DecodeJSON or MergePatch. Bodies
+// over 1 MiB are answered with apperror.ErrBodyTooLarge.
+func ReadJSONBody(c echo.Context) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxJSONBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, apperror.ErrBodyTooLarge
+	}
+	if err != nil {
+		return nil, apperror.InvalidBody(err)
+	}
+	return body, nil
+}
+
+// DecodeJSON decodes a JSON object into target, a pointer to a request
+// struct holding the current values; fields the object leaves out keep
+// them. Like MergePatch it rejects members naming a field of readOnly or no
+// field at all, so nothing the client sent is silently dropped.
+func DecodeJSON(target interface{}, body []byte, readOnly map[string]string) error {
+	object, err := jsonObject(body)
+	if err != nil {
+		return err
+	}
+	if err := checkJSONFields(target, object, readOnly); err != nil {
+		return err
+	}
+	return decodeJSONError(json.Unmarshal(body, target))
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to target, a pointer to
+// a request struct holding the current values. Every member of the patch
+// replaces the field with the same JSON name and null resets it to its zero
+// value. Members naming a field of readOnly, mapped to a hint on how to
+// change it instead, or no field of target at all are rejected with
+// apperror.ErrValidationFailed. It returns the names of the patched fields.
+func MergePatch(target interface{}, body []byte, readOnly map[string]string) ([]string, error) {
+	patch, err := jsonObject(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkJSONFields(target, patch, readOnly); err != nil {
+		return nil, err
+	}
+
+	current, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(current, &document); err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(patch))
+	for name, value := range patch {
+		fields = append(fields, name)
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			delete(document, name)
+		} else {
+			document[name] = value
+		}
+	}
+	sort.Strings(fields)
+
+	merged, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	// start from zero values so removed members reset their field
+	reflect.ValueOf(target).Elem().Set(reflect.Zero(reflect.TypeOf(target).Elem()))
+	if err := decodeJSONError(json.Unmarshal(merged, target)); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// jsonObject splits a body into the members of its JSON object.
+func jsonObject(body []byte) (map[string]json.RawMessage, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil || object == nil {
+		return nil, apperror.ErrInvalidBody.WithMessage("The body must be a JSON object")
+	}
+	return object, nil
+}
+
+// checkJSONFields rejects members of object that name a read-only field or
+// no field of target, listing every one of them in the error details.
+func checkJSONFields(target interface{}, object map[string]json.RawMessage, readOnly map[string]string) error {
+	known := jsonFields(reflect.TypeOf(target).Elem())
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var invalid []models.FieldError
+	for _, name := range names {
+		if hint, ok := readOnly[name]; ok {
+			invalid = append(invalid, models.FieldError{Field: name, Rule: "read_only", Message: hint})
+		} else if !known[name] {
+			invalid = append(invalid, models.FieldError{Field: name, Rule: "unknown", Message: "is not a known field"})
+		}
+	}
+	if len(invalid) > 0 {
+		return apperror.ErrValidationFailed.WithDetails(invalid)
+	}
+	return nil
+}
+
+// decodeJSONError describes a failed decode of a request struct.
+func decodeJSONError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return apperror.ErrInvalidBody.WithMessage(typeErr.Field + " must be a " + typeErr.Type.String())
+	}
+	return apperror.ErrInvalidBody.Wrap(err)
+}
+
+// jsonFields returns the JSON names of the fields of a struct type.
+func jsonFields(structType reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"attendance/apperror"
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+type patchRequest struct {
+	Name    string   `json:"name"`
+	Phone   *string  `json:"phone"`
+	Age     int      `json:"age"`
+	Tags    []string `json:"tags"`
+	Secret  string   `json:"-"`
+	private string
+}
+
+var patchReadOnly = map[string]string{"role": "use PUT /roles"}
+
+func TestMergePatch(t *testing.T) {
+	phone := "+62 812 0000 0000"
+	current := patchRequest{Name: "Jhon", Phone: &phone, Age: 30, Tags: []string{"a"}}
+
+	tests := []struct {
+		name   string
+		body   string
+		want   patchRequest
+		fields []string
+		code   apperror.Code
+	}{
+		{name: "empty patch", body: `{}`, want: current, fields: []string{}},
+		{name: "replace", body: `{"name":"Jane","age":31}`, want: patchRequest{Name: "Jane", Phone: &phone, Age: 31, Tags: []string{"a"}}, fields: []string{"age", "name"}},
+		{name: "null resets", body: `{"phone":null,"tags":null}`, want: patchRequest{Name: "Jhon", Age: 30}, fields: []string{"phone", "tags"}},
+		{name: "arrays are replaced", body: `{"tags":["b","c"]}`, want: patchRequest{Name: "Jhon", Phone: &phone, Age: 30, Tags: []string{"b", "c"}}, fields: []string{"tags"}},
+		{name: "read only", body: `{"role":"admin"}`, code: apperror.CodeValidationFailed},
+		{name: "unknown", body: `{"nickname":"J"}`, code: apperror.CodeValidationFailed},
+		{name: "ignored field", body: `{"Secret":"x"}`, code: apperror.CodeValidationFailed},
+		{name: "wrong type", body: `{"age":"old"}`, code: apperror.CodeInvalidBody},
+		{name: "not an object", body: `["name"]`, code: apperror.CodeInvalidBody},
+		{name: "null body", body: `null`, code: apperror.CodeInvalidBody},
+		{name: "malformed", body: `{"name":`, code: apperror.CodeInvalidBody},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := current
+			fields, err := MergePatch(&target, []byte(test.body), patchReadOnly)
+			if test.code != "" {
+				var appErr *apperror.Error
+				if !errors.As(err, &appErr) || appErr.Code != test.code {
+					t.Fatalf("got %v, want code %s", err, test.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(target, test.want) {
+				t.Errorf("got %+v, want %+v", target, test.want)
+			}
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("fields %v, want %v", fields, test.fields)
+			}
+		})
+	}
+}
+
+func TestMergePatchDetails(t *testing.T) {
+	target := patchRequest{}
+	_, err := MergePatch(&target, []byte(`{"role":"admin","nickname":"J","name":"Jane"}`), patchReadOnly)
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("got %v", err)
+	}
+	want := []models.FieldError{
+		{Field: "nickname", Rule: "unknown", Message: "is not a known field"},
+		{Field: "role", Rule: "read_only", Message: "use PUT /roles"},
+	}
+	if !reflect.DeepEqual(appErr.Details, want) {
+		t.Errorf("details %+v, want %+v", appErr.Details, want)
+	}
+	if target.Name != "" {
+		t.Error("a rejected patch changed the target")
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	phone := "+62 812 0000 0000"
+	current := patchRequest{Name: "Jhon", Phone: &phone, Age: 30}
+
+	tests := []struct {
+		name string
+		body string
+		want patchRequest
+		code apperror.Code
+	}{
+		{name: "missing fields keep their value", body: `{"name":"Jane"}`, want: patchRequest{Name: "Jane", Phone: &phone, Age: 30}},
+		{name: "read only", body: `{"name":"Jane","role":"admin"}`, code: apperror.CodeValidationFailed},
+		{name: "unknown", body: `{"password":"secret"}`, code: apperror.CodeValidationFailed},
+		{name: "wrong type", body: `{"age":"old"}`, code: apperror.CodeInvalidBody},
+		{name: "not an object", body: `"Jane"`, code: apperror.CodeInvalidBody},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := current
+			err := DecodeJSON(&target, []byte(test.body), patchReadOnly)
+			if test.code != "" {
+				var appErr *apperror.Error
+				if !errors.As(err, &appErr) || appErr.Code != test.code {
+					t.Fatalf("got %v, want code %s", err, test.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(target, test.want) {
+				t.Errorf("got %+v, want %+v", target, test.want)
+			}
+		})
+	}
+}
+
+func TestReadJSONBody(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		code apperror.Code
+	}{
+		{name: "small", size: 100},
+		{name: "at the limit", size: maxJSONBodySize},
+		{name: "over the limit", size: maxJSONBodySize + 1, code: apperror.CodeBodyTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(strings.Repeat(" ", test.size)))
+			c := echo.New().NewContext(request, httptest.NewRecorder())
+			body, err := ReadJSONBody(c)
+			if test.code != "" {
+				var appErr *apperror.Error
+				if !errors.As(err, &appErr) || appErr.Code != test.code || appErr.Status != http.StatusRequestEntityTooLarge {
+					t.Fatalf("got %v, want code %s", err, test.code)
+				}
+				return
+			}
+			if err != nil || len(body) != test.size {
+				t.Errorf("got %d bytes, %v", len(body), err)
+			}
+		})
+	}
+}
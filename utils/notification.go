@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	htmltemplate "html/template"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var (
+	textTemplates = template.Must(template.ParseFS(templateFiles, "templates/*.subject.tmpl", "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/*.html.tmpl"))
+)
+
+// Notify renders the templates of event with data and queues the email in
+// the outbox. It does not block on delivery.
+func Notify(db *gorm.DB, event, to string, data interface{}) error {
+	mail, err := RenderMail(event, to, data)
+	if err != nil {
+		return err
+	}
+
+	return db.Create(&models.Notification{
+		Event:         event,
+		Recipient:     mail.To,
+		Subject:       mail.Subject,
+		TextBody:      mail.Text,
+		HTMLBody:      mail.HTML,
+		Status:        models.NotificationPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// RenderMail renders the subject, text and optional HTML templates of event
+// into a message for to.
+func RenderMail(event, to string, data interface{}) (Mail, error) {
+	subject, err := renderText(event+".subject.tmpl", data)
+	if err != nil {
+		return Mail{}, err
+	}
+	text, err := renderText(event+".txt.tmpl", data)
+	if err != nil {
+		return Mail{}, err
+	}
+
+	var html bytes.Buffer
+	if tmpl := htmlTemplates.Lookup(event + ".html.tmpl"); tmpl != nil {
+		if err := tmpl.Execute(&html, data); err != nil {
+			return Mail{}, err
+		}
+	}
+	return Mail{To: to, Subject: strings.TrimSpace(subject), Text: text, HTML: html.String()}, nil
+}
+
+// StartOutboxWorker sends queued notifications until ctx is cancelled.
+// Failed deliveries are retried with exponential backoff and marked failed
+// after OUTBOX_MAX_ATTEMPTS (default 8) attempts.
+func StartOutboxWorker(ctx context.Context, db *gorm.DB, transport MailTransport) {
+	interval := time.Duration(envInt("OUTBOX_POLL_SECONDS", 5)) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := DeliverPending(db, transport); err != nil {
+			log.Println("Error delivering notifications:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverPending sends every notification that is due. Rows are claimed
+// with a conditional update so several server instances can share a queue.
+func DeliverPending(db *gorm.DB, transport MailTransport) error {
+	now := time.Now()
+
+	// release rows left in "sending" by an instance that died mid-delivery
+	db.Model(&models.Notification{}).
+		Where("status = ? AND updated_at < ?", models.NotificationSending, now.Add(-5*time.Minute)).
+		Update("status", models.NotificationPending)
+
+	var due []models.Notification
+	if err := db.Where("status = ? AND next_attempt_at <= ?", models.NotificationPending, now).
+		Order("next_attempt_at").Limit(50).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for _, notification := range due {
+		claimed := db.Model(&models.Notification{}).
+			Where("id = ? AND status = ?", notification.ID, models.NotificationPending).
+			Update("status", models.NotificationSending)
+		if claimed.Error != nil || claimed.RowsAffected == 0 {
+			continue
+		}
+		deliver(db, transport, notification)
+	}
+	return nil
+}
+
+// RetryNotification puts a failed notification back in the queue.
+func RetryNotification(db *gorm.DB, notification *models.Notification) error {
+	notification.Status = models.NotificationPending
+	notification.Attempts = 0
+	notification.NextAttemptAt = time.Now()
+	return db.Model(notification).Select("status", "attempts", "next_attempt_at").Updates(notification).Error
+}
+
+func deliver(db *gorm.DB, transport MailTransport, notification models.Notification) {
+	err := transport.Send(Mail{
+		To:      notification.Recipient,
+		Subject: notification.Subject,
+		Text:    notification.TextBody,
+		HTML:    notification.HTMLBody,
+	})
+
+	updates := map[string]interface{}{"attempts": notification.Attempts + 1}
+	if err == nil {
+		updates["status"] = models.NotificationSent
+		updates["sent_at"] = time.Now()
+		updates["last_error"] = ""
+	} else {
+		updates["last_error"] = err.Error()
+		if notification.Attempts+1 >= envInt("OUTBOX_MAX_ATTEMPTS", 8) {
+			updates["status"] = models.NotificationFailed
+		} else {
+			updates["status"] = models.NotificationPending
+			updates["next_attempt_at"] = time.Now().Add(Backoff(notification.Attempts + 1))
+		}
+	}
+
+	if err := db.Model(&models.Notification{}).Where("id = ?", notification.ID).Updates(updates).Error; err != nil {
+		log.Println("Error updating notification:", err)
+	}
+}
+
+// Backoff returns the delay before retry number attempt: 30s doubling each
+// time, capped at one hour.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := 30 * time.Second * time.Duration(math.Pow(2, math.Min(float64(attempt-1), 7)))
+	if delay > time.Hour {
+		return time.Hour
+	}
+	return delay
+}
+
+func renderText(name string, data interface{}) (string, error) {
+	var out bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&out, name, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
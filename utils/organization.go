@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"errors"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+// ReportingSubtree returns the IDs of everyone reporting to managerID,
+// directly or indirectly. The manager is not included.
+func ReportingSubtree(db *gorm.DB, managerID uint) ([]uint, error) {
+	children, err := reportingLines(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	queue := []uint{managerID}
+	seen := map[uint]bool{managerID: true}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// ValidateManager rejects manager assignments that point at a missing
+// employee or would make the reporting lines circular.
+func ValidateManager(db *gorm.DB, employeeID uint, managerID *uint) error {
+	if managerID == nil {
+		return nil
+	}
+	if *managerID == employeeID {
+		return errors.New("an employee cannot report to themselves")
+	}
+	var manager models.Employee
+	if err := db.Select("id").First(&manager, *managerID).Error; err != nil {
+		return errors.New("manager not found")
+	}
+	reports, err := ReportingSubtree(db, employeeID)
+	if err != nil {
+		return err
+	}
+	for _, id := range reports {
+		if id == *managerID {
+			return errors.New("manager reports to this employee")
+		}
+	}
+	return nil
+}
+
+// BuildOrgChart returns the reporting tree below root, or the whole
+// organisation (one tree per employee without a manager) when root is nil.
+// Only the employees db selects are charted; when root is nil, those whose
+// manager is left out head a tree of their own.
+func BuildOrgChart(db *gorm.DB, root *uint) ([]models.OrgChartNode, error) {
+	var employees []models.Employee
+	if err := db.Select("id", "fullname", "username", "role", "department_id", "team_id", "manager_id").Order("fullname").Find(&employees).Error; err != nil {
+		return nil, err
+	}
+
+	charted := map[uint]bool{}
+	for _, e := range employees {
+		charted[e.ID] = true
+	}
+
+	byManager := map[uint][]models.Employee{}
+	var tops []models.Employee
+	for _, e := range employees {
+		switch {
+		case root != nil && e.ID == *root:
+			tops = append(tops, e)
+		case root == nil && (e.ManagerID == nil || !charted[*e.ManagerID]):
+			tops = append(tops, e)
+		}
+		if e.ManagerID != nil {
+			byManager[*e.ManagerID] = append(byManager[*e.ManagerID], e)
+		}
+	}
+
+	var build func(e models.Employee, seen map[uint]bool) models.OrgChartNode
+	build = func(e models.Employee, seen map[uint]bool) models.OrgChartNode {
+		seen[e.ID] = true
+		node := models.OrgChartNode{
+			ID:           e.ID,
+			Fullname:     e.Fullname,
+			Username:     e.Username,
+			Role:         e.Role,
+			DepartmentID: e.DepartmentID,
+			TeamID:       e.TeamID,
+			Reports:      []models.OrgChartNode{},
+		}
+		for _, report := range byManager[e.ID] {
+			if !seen[report.ID] {
+				node.Reports = append(node.Reports, build(report, seen))
+			}
+		}
+		return node
+	}
+
+	chart := make([]models.OrgChartNode, 0, len(tops))
+	for _, top := range tops {
+		chart = append(chart, build(top, map[uint]bool{}))
+	}
+	return chart, nil
+}
+
+// BuildDepartmentTree nests departments and their teams.
+func BuildDepartmentTree(db *gorm.DB) ([]models.DepartmentNode, error) {
+	var departments []models.Department
+	if err := db.Order("name").Find(&departments).Error; err != nil {
+		return nil, err
+	}
+	var teams []models.Team
+	if err := db.Order("name").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+
+	teamsByParent := map[uint][]models.Team{}
+	rootTeams := map[uint][]models.Team{}
+	for _, t := range teams {
+		if t.ParentID != nil {
+			teamsByParent[*t.ParentID] = append(teamsByParent[*t.ParentID], t)
+		} else {
+			rootTeams[t.DepartmentID] = append(rootTeams[t.DepartmentID], t)
+		}
+	}
+	var buildTeam func(t models.Team) models.TeamNode
+	buildTeam = func(t models.Team) models.TeamNode {
+		node := models.TeamNode{Team: t, Children: []models.TeamNode{}}
+		for _, child := range teamsByParent[t.ID] {
+			node.Children = append(node.Children, buildTeam(child))
+		}
+		return node
+	}
+
+	deptsByParent := map[uint][]models.Department{}
+	var roots []models.Department
+	for _, d := range departments {
+		if d.ParentID != nil {
+			deptsByParent[*d.ParentID] = append(deptsByParent[*d.ParentID], d)
+		} else {
+			roots = append(roots, d)
+		}
+	}
+	var buildDept func(d models.Department) models.DepartmentNode
+	buildDept = func(d models.Department) models.DepartmentNode {
+		node := models.DepartmentNode{Department: d, Teams: []models.TeamNode{}, Children: []models.DepartmentNode{}}
+		for _, t := range rootTeams[d.ID] {
+			node.Teams = append(node.Teams, buildTeam(t))
+		}
+		for _, child := range deptsByParent[d.ID] {
+			node.Children = append(node.Children, buildDept(child))
+		}
+		return node
+	}
+
+	tree := make([]models.DepartmentNode, 0, len(roots))
+	for _, d := range roots {
+		tree = append(tree, buildDept(d))
+	}
+	return tree, nil
+}
+
+// ValidateDepartmentParent rejects a parent that is missing or that sits
+// below the department itself.
+func ValidateDepartmentParent(db *gorm.DB, departmentID uint, parentID *uint) error {
+	for current := parentID; current != nil; {
+		if departmentID != 0 && *current == departmentID {
+			return errors.New("department cannot be nested inside itself")
+		}
+		var parent models.Department
+		if err := db.First(&parent, *current).Error; err != nil {
+			return errors.New("parent department not found")
+		}
+		current = parent.ParentID
+	}
+	return nil
+}
+
+// ValidateTeamParent rejects a parent team that is missing, belongs to a
+// different department or sits below the team itself.
+func ValidateTeamParent(db *gorm.DB, team models.Team) error {
+	var department models.Department
+	if err := db.First(&department, team.DepartmentID).Error; err != nil {
+		return errors.New("department not found")
+	}
+	for current := team.ParentID; current != nil; {
+		if team.ID != 0 && *current == team.ID {
+			return errors.New("team cannot be nested inside itself")
+		}
+		var parent models.Team
+		if err := db.First(&parent, *current).Error; err != nil {
+			return errors.New("parent team not found")
+		}
+		if parent.DepartmentID != team.DepartmentID {
+			return errors.New("parent team belongs to another department")
+		}
+		current = parent.ParentID
+	}
+	return nil
+}
+
+func reportingLines(db *gorm.DB) (map[uint][]uint, error) {
+	var employees []models.Employee
+	if err := db.Select("id", "manager_id").Where("manager_id IS NOT NULL").Find(&employees).Error; err != nil {
+		return nil, err
+	}
+	children := map[uint][]uint{}
+	for _, e := range employees {
+		children[*e.ManagerID] = append(children[*e.ManagerID], e.ID)
+	}
+	return children, nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultPageLimit is the page size when a list request gives none.
+	DefaultPageLimit = 50
+	// MaxPageLimit is the largest page size a list request may ask for.
+	MaxPageLimit = 200
+)
+
+// ParsePage reads the page and limit query parameters. Missing values
+// default to the first page of DefaultPageLimit items; anything else must
+// be a positive number, with limit at most MaxPageLimit.
+func ParsePage(c echo.Context) (page, limit int, err error) {
+	page, limit = 1, DefaultPageLimit
+	if value := c.QueryParam("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			return 0, 0, errors.New("page must be a positive number")
+		}
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > MaxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+		}
+	}
+	return page, limit, nil
+}
+
+// PageInfo describes the page of a list response, with links to the
+// neighbouring pages that keep every other query parameter.
+func PageInfo(c echo.Context, page, limit int, total int64) models.Pagination {
+	info := models.Pagination{Total: total, Page: page, Limit: limit}
+	if int64(page*limit) < total {
+		info.Next = pageLink(c, page+1)
+	}
+	if page > 1 {
+		info.Prev = pageLink(c, page-1)
+	}
+	return info
+}
+
+func pageLink(c echo.Context, page int) string {
+	link := *c.Request().URL
+	query := link.Query()
+	query.Set("page", strconv.Itoa(page))
+	link.RawQuery = query.Encode()
+	return link.RequestURI()
+}
+
+// ParseSort turns a sort parameter such as "fullname,-created_at" into
+// ORDER BY columns; a leading "-" sorts descending. allowed maps the names
+// clients may use to columns. The primary key is always the last column so
+// pages are stable.
+func ParseSort(param string, allowed map[string]string, fallback string) ([]clause.OrderByColumn, error) {
+	if param == "" {
+		param = fallback
+	}
+	var columns []clause.OrderByColumn
+	seen := map[string]bool{}
+	for _, field := range splitList(param) {
+		desc := strings.HasPrefix(field, "-")
+		name := strings.TrimPrefix(field, "-")
+		column, ok := allowed[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %s", name)
+		}
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
+	}
+	if !seen["id"] {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+	return columns, nil
+}
+
+// LikePattern escapes the LIKE wildcards in a search term and wraps it in
+// %, so the term matches anywhere in a column.
+func LikePattern(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(term) + "%"
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"attendance/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken is returned for unknown, used and expired tokens alike.
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetTTL is how long a reset token stays valid, configured in
+// minutes through PASSWORD_RESET_TTL (default 60).
+func PasswordResetTTL() time.Duration {
+	return time.Duration(envInt("PASSWORD_RESET_TTL", 60)) * time.Minute
+}
+
+// InvitationTTL is how long the link sent to imported employees stays
+// valid, configured in hours through INVITATION_TTL_HOURS (default 72).
+func InvitationTTL() time.Duration {
+	return time.Duration(envInt("INVITATION_TTL_HOURS", 72)) * time.Hour
+}
+
+// AppURL is the public base URL used in links sent by email.
+func AppURL() string {
+	return envOr("APP_URL", "http://localhost:8080")
+}
+
+// PasswordResetLink is the link sent by email for a reset token.
+func PasswordResetLink(token string) string {
+	return fmt.Sprintf("%s/reset-password?token=%s", AppURL(), url.QueryEscape(token))
+}
+
+// IssuePasswordReset creates a new reset token for the employee, voiding any
+// earlier one, and returns the plain token.
+func IssuePasswordReset(db *gorm.DB, employeeID uint) (string, error) {
+	return issueResetToken(db, employeeID, PasswordResetTTL())
+}
+
+// IssueInvitation creates the reset token a new employee uses to choose
+// their first password.
+func IssueInvitation(db *gorm.DB, employeeID uint) (string, error) {
+	return issueResetToken(db, employeeID, InvitationTTL())
+}
+
+func issueResetToken(db *gorm.DB, employeeID uint, ttl time.Duration) (string, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("employee_id = ? AND used_at IS NULL", employeeID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			EmployeeID: employeeID,
+			TokenHash:  HashToken(token),
+			ExpiresAt:  now.Add(ttl),
+		}).Error
+	})
+	return token, err
+}
+
+// ResetPassword consumes a reset token, sets the new password and logs the
+// employee out everywhere.
+func ResetPassword(db *gorm.DB, token, newPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", HashToken(token), time.Now()).
+			First(&reset).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		now := time.Now()
+		// the used_at condition guards against two concurrent confirmations
+		result := tx.Model(&reset).Where("used_at IS NULL").Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		if err := tx.Model(&models.Employee{}).Where("id = ?", reset.EmployeeID).
+			Updates(map[string]interface{}{"password": string(hash), "password_changed_at": now, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		return RevokeSessions(tx, reset.EmployeeID)
+	})
+}
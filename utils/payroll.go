@@ -0,0 +1,354 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// OvertimeRate applies to hours worked beyond the scheduled shift.
+	OvertimeRate = "1.5"
+	// RestDayOvertimeRate applies to any hours worked on weekends and holidays.
+	RestDayOvertimeRate = "2.0"
+)
+
+// OvertimeRates lists the overtime rates in the order payroll exports them.
+var OvertimeRates = []string{OvertimeRate, RestDayOvertimeRate}
+
+// PayrollColumn is one column of a payroll export. Width is only used by the
+// fixed-width format.
+type PayrollColumn struct {
+	Name  string
+	Width int
+	Value func(row models.PayrollRow) string
+}
+
+// PayrollColumns holds every column that can appear in an export.
+var PayrollColumns = map[string]PayrollColumn{}
+
+// PayrollLayouts are the named column layouts accepted by the export.
+var PayrollLayouts = map[string][]string{
+	"default": {"employee_id", "username", "fullname", "regular_hours", "overtime_1.5", "overtime_2.0", "leave_annual", "leave_sick", "leave_unpaid", "leave_other", "absences", "late_minutes"},
+	"summary": {"employee_id", "fullname", "regular_hours", "overtime_total", "leave_total", "absences"},
+}
+
+func init() {
+	add := func(name string, width int, value func(row models.PayrollRow) string) {
+		PayrollColumns[name] = PayrollColumn{Name: name, Width: width, Value: value}
+	}
+	add("employee_id", 8, func(r models.PayrollRow) string { return strconv.Itoa(r.EmployeeID) })
+	add("username", 20, func(r models.PayrollRow) string { return r.Username })
+	add("fullname", 30, func(r models.PayrollRow) string { return r.Fullname })
+	add("regular_hours", 10, func(r models.PayrollRow) string { return formatHours(r.RegularHours) })
+	add("absences", 8, func(r models.PayrollRow) string { return strconv.Itoa(r.Absences) })
+	add("late_minutes", 8, func(r models.PayrollRow) string { return strconv.Itoa(r.LateMinutes) })
+	add("overtime_total", 10, func(r models.PayrollRow) string { return formatHours(sumHours(r.Overtime)) })
+	add("leave_total", 10, func(r models.PayrollRow) string { return formatHours(sumHours(r.Leave)) })
+	for _, rate := range OvertimeRates {
+		rate := rate
+		add("overtime_"+rate, 10, func(r models.PayrollRow) string { return formatHours(r.Overtime[rate]) })
+	}
+	for _, leaveType := range models.LeaveTypes {
+		leaveType := leaveType
+		add("leave_"+leaveType, 10, func(r models.PayrollRow) string { return formatHours(r.Leave[leaveType]) })
+	}
+}
+
+// ResolvePayrollColumns picks the export columns from an explicit comma
+// separated list, falling back to a named layout.
+func ResolvePayrollColumns(layout, columns string) ([]PayrollColumn, error) {
+	var names []string
+	if columns != "" {
+		names = strings.Split(columns, ",")
+	} else {
+		if layout == "" {
+			layout = "default"
+		}
+		var ok bool
+		if names, ok = PayrollLayouts[layout]; !ok {
+			return nil, fmt.Errorf("unknown layout %q", layout)
+		}
+	}
+
+	resolved := make([]PayrollColumn, 0, len(names))
+	for _, name := range names {
+		column, ok := PayrollColumns[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		resolved = append(resolved, column)
+	}
+	return resolved, nil
+}
+
+// PayrollRows returns the rows of a pay period. Closed periods are served
+// from their snapshot so that re-exports are reproducible.
+func PayrollRows(db *gorm.DB, period models.PayPeriod) ([]models.PayrollRow, error) {
+	if period.Status == models.PayPeriodClosed {
+		var rows []models.PayrollRow
+		if err := json.Unmarshal([]byte(period.Snapshot), &rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+	return BuildPayroll(db, period.StartDate, period.EndDate)
+}
+
+// ClosePayPeriod computes the period's rows and freezes them.
+func ClosePayPeriod(db *gorm.DB, period *models.PayPeriod) error {
+	if period.Status == models.PayPeriodClosed {
+		return errors.New("pay period is already closed")
+	}
+	rows, err := BuildPayroll(db, period.StartDate, period.EndDate)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	period.Status = models.PayPeriodClosed
+	period.ClosedAt = &now
+	period.Snapshot = string(snapshot)
+	return db.Save(period).Error
+}
+
+// BuildPayroll computes worked, overtime and leave hours for every employee
+// employed between from and to, both inclusive.
+func BuildPayroll(db *gorm.DB, from, to time.Time) ([]models.PayrollRow, error) {
+	from, to = StartOfDay(from), StartOfDay(to)
+	until := to.AddDate(0, 0, 1)
+
+	var employees []models.Employee
+	if err := EmployedBetween(db, from, to).Order("id").Find(&employees).Error; err != nil {
+		return nil, err
+	}
+	holidays, err := HolidaySet(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.PayrollRow, 0, len(employees))
+	for _, employee := range employees {
+		employeeID := int(employee.ID)
+		schedule := ScheduleFor(db, employeeID)
+
+		var clockIns []models.ClockIn
+		if err := db.Where("employee_id = ? AND clock_in_time >= ? AND clock_in_time < ?", employeeID, from, until).Order("clock_in_time").Find(&clockIns).Error; err != nil {
+			return nil, err
+		}
+		var clockOuts []models.ClockOut
+		if err := db.Where("employee_id = ? AND clock_in_id IN (?)", employeeID, clockInIDs(clockIns)).Find(&clockOuts).Error; err != nil {
+			return nil, err
+		}
+		var leaves []models.Leave
+		if err := db.Where("employee_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", employeeID, models.LeaveApproved, to, from).Find(&leaves).Error; err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, payrollRow(employee, schedule, holidays, leaves, clockIns, clockOuts, from, to))
+	}
+	return rows, nil
+}
+
+// payrollRow classifies every day from from to to, both inclusive, for one
+// employee. Days before the hire date or after the termination date are
+// skipped, so joining or leaving during the period does not count as
+// absence.
+func payrollRow(employee models.Employee, schedule models.WorkSchedule, holidays map[string]bool, leaves []models.Leave,
+	clockIns []models.ClockIn, clockOuts []models.ClockOut, from, to time.Time) models.PayrollRow {
+	first, last := StartOfDay(from), StartOfDay(to)
+	if employee.HireDate != nil && StartOfDay(*employee.HireDate).After(first) {
+		first = StartOfDay(*employee.HireDate)
+	}
+	if employee.TerminationDate != nil && StartOfDay(*employee.TerminationDate).Before(last) {
+		last = StartOfDay(*employee.TerminationDate)
+	}
+
+	row := models.PayrollRow{
+		EmployeeID: int(employee.ID),
+		Username:   employee.Username,
+		Fullname:   employee.Fullname,
+		Overtime:   map[string]float64{},
+		Leave:      map[string]float64{},
+	}
+	worked, firstIn := dailyAttendance(clockIns, clockOuts)
+
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		key := day.Format(DateLayout)
+		hours := worked[key]
+		start, end := ShiftBounds(schedule, day)
+		shift := end.Sub(start).Hours()
+
+		if !IsWorkDay(schedule, day) || holidays[key] {
+			row.Overtime[RestDayOvertimeRate] += hours
+			continue
+		}
+		if leaveType := leaveOn(leaves, day); leaveType != "" {
+			row.Leave[leaveType] += shift
+			row.Overtime[OvertimeRate] += hours
+			continue
+		}
+		if hours == 0 {
+			row.Absences++
+			continue
+		}
+		if late := firstIn[key].Sub(start); late > 0 {
+			row.LateMinutes += int(late.Minutes())
+		}
+		row.RegularHours += math.Min(hours, shift)
+		row.Overtime[OvertimeRate] += math.Max(hours-shift, 0)
+	}
+
+	row.RegularHours = roundHours(row.RegularHours)
+	for k, v := range row.Overtime {
+		row.Overtime[k] = roundHours(v)
+	}
+	for k, v := range row.Leave {
+		row.Leave[k] = roundHours(v)
+	}
+	return row
+}
+
+// WritePayroll renders rows in the requested format: csv, json or fixed.
+func WritePayroll(w io.Writer, format string, columns []PayrollColumn, rows []models.PayrollRow) error {
+	switch format {
+	case "", "csv":
+		writer := csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Name
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				record[i] = column.Value(row)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		records := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			record := make(map[string]string, len(columns))
+			for _, column := range columns {
+				record[column.Name] = column.Value(row)
+			}
+			records = append(records, record)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case "fixed":
+		for _, row := range rows {
+			var line strings.Builder
+			for _, column := range columns {
+				value := column.Value(row)
+				// cut whole characters so multibyte names stay valid UTF-8
+				if runes := []rune(value); len(runes) > column.Width {
+					value = string(runes[:column.Width])
+				}
+				line.WriteString(fmt.Sprintf("%-*s", column.Width, value))
+			}
+			line.WriteString("\r\n")
+			if _, err := io.WriteString(w, line.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// PayrollContentType returns the MIME type of an export format.
+func PayrollContentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	case "fixed":
+		return "text/plain; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// dailyAttendance sums the hours of every completed session per clock-in day
+// and records the first clock-in of each day.
+func dailyAttendance(clockIns []models.ClockIn, clockOuts []models.ClockOut) (map[string]float64, map[string]time.Time) {
+	outs := make(map[uint]models.ClockOut, len(clockOuts))
+	for _, out := range clockOuts {
+		outs[out.ClockInID] = out
+	}
+
+	worked := map[string]float64{}
+	firstIn := map[string]time.Time{}
+	for _, in := range clockIns {
+		key := in.ClockInTime.In(time.Local).Format(DateLayout)
+		if first, ok := firstIn[key]; !ok || in.ClockInTime.Before(first) {
+			firstIn[key] = in.ClockInTime
+		}
+		if out, ok := outs[in.ID]; ok {
+			worked[key] += out.ClockOutTime.Sub(in.ClockInTime).Hours()
+		}
+	}
+	return worked, firstIn
+}
+
+func clockInIDs(clockIns []models.ClockIn) []uint {
+	ids := make([]uint, 0, len(clockIns))
+	for _, in := range clockIns {
+		ids = append(ids, in.ID)
+	}
+	if len(ids) == 0 {
+		ids = append(ids, 0)
+	}
+	return ids
+}
+
+func leaveOn(leaves []models.Leave, day time.Time) string {
+	for _, leave := range leaves {
+		if !day.Before(StartOfDay(leave.StartDate)) && !day.After(StartOfDay(leave.EndDate)) {
+			return leave.Type
+		}
+	}
+	return ""
+}
+
+func sumHours(hours map[string]float64) float64 {
+	keys := make([]string, 0, len(hours))
+	for k := range hours {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var total float64
+	for _, k := range keys {
+		total += hours[k]
+	}
+	return total
+}
+
+func roundHours(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
+
+func formatHours(hours float64) string {
+	return strconv.FormatFloat(hours, 'f', 2, 64)
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"attendance/models"
+)
+
+func TestWritePayrollFixedWidth(t *testing.T) {
+	columns, err := ResolvePayrollColumns("", "employee_id,fullname")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fullname string
+		want     string
+	}{
+		{"padded", "Jhon Doe", "7       Jhon Doe                      \r\n"},
+		{"ascii cut", strings.Repeat("a", 40), "7       " + strings.Repeat("a", 30) + "\r\n"},
+		{"multibyte cut", strings.Repeat("é", 40), "7       " + strings.Repeat("é", 30) + "\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			rows := []models.PayrollRow{{EmployeeID: 7, Fullname: test.fullname}}
+			if err := WritePayroll(&out, "fixed", columns, rows); err != nil {
+				t.Fatal(err)
+			}
+			if !utf8.Valid(out.Bytes()) {
+				t.Fatalf("output is not valid UTF-8: %q", out.String())
+			}
+			if out.String() != test.want {
+				t.Errorf("got %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestPayrollRowDays(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 5, d, 0, 0, 0, 0, time.Local) }
+	at := func(d, hour, minute int) time.Time { return time.Date(2023, 5, d, hour, minute, 0, 0, time.Local) }
+	date := func(d int) *time.Time { t := day(d); return &t }
+	lastMonth := day(1).AddDate(0, -1, 0)
+	// Monday 1 May to Sunday 7 May 2023 on the default Monday to Friday
+	// schedule, 09:00 to 17:00
+	from, to := day(1), day(7)
+
+	tests := []struct {
+		name            string
+		hired           *time.Time
+		terminated      *time.Time
+		holidays        map[string]bool
+		leaves          []models.Leave
+		sessions        [][2]time.Time
+		wantAbsences    int
+		wantRegular     float64
+		wantOvertime    float64
+		wantRestDay     float64
+		wantLeave       float64
+		wantLateMinutes int
+	}{
+		{name: "absent all week", wantAbsences: 5},
+		{name: "hired midweek", hired: date(3), wantAbsences: 3},
+		{name: "hired before the period", hired: &lastMonth, wantAbsences: 5},
+		{name: "terminated midweek", terminated: date(2), wantAbsences: 2},
+		{name: "hired and terminated within the period", hired: date(2), terminated: date(4), wantAbsences: 3},
+		{name: "hired after the period", hired: date(8), wantAbsences: 0},
+		{name: "holiday", holidays: map[string]bool{"2023-05-02": true}, wantAbsences: 4},
+		{name: "leave", leaves: []models.Leave{{Type: models.LeaveAnnual, StartDate: day(3), EndDate: day(3)}}, wantAbsences: 4, wantLeave: 8},
+		{
+			name:            "late with overtime",
+			sessions:        [][2]time.Time{{at(1, 9, 30), at(1, 18, 30)}},
+			wantAbsences:    4,
+			wantRegular:     8,
+			wantOvertime:    1,
+			wantLateMinutes: 30,
+		},
+		{name: "weekend work", sessions: [][2]time.Time{{at(6, 10, 0), at(6, 14, 0)}}, wantAbsences: 5, wantRestDay: 4},
+		{name: "work before hire is ignored", hired: date(2), sessions: [][2]time.Time{{at(1, 9, 0), at(1, 17, 0)}}, wantAbsences: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			employee := models.Employee{Username: "jhon", HireDate: test.hired, TerminationDate: test.terminated}
+			employee.ID = 7
+			var clockIns []models.ClockIn
+			var clockOuts []models.ClockOut
+			for i, session := range test.sessions {
+				clockIns = append(clockIns, models.ClockIn{ID: uint(i + 1), EmployeeID: 7, ClockInTime: session[0]})
+				clockOuts = append(clockOuts, models.ClockOut{ID: uint(i + 1), EmployeeID: 7, ClockInID: uint(i + 1), ClockOutTime: session[1]})
+			}
+
+			row := payrollRow(employee, DefaultSchedule, test.holidays, test.leaves, clockIns, clockOuts, from, to)
+			if row.EmployeeID != 7 || row.Username != "jhon" {
+				t.Errorf("row is for %d %q", row.EmployeeID, row.Username)
+			}
+			if row.Absences != test.wantAbsences {
+				t.Errorf("absences %d, want %d", row.Absences, test.wantAbsences)
+			}
+			if row.RegularHours != test.wantRegular {
+				t.Errorf("regular hours %v, want %v", row.RegularHours, test.wantRegular)
+			}
+			if row.Overtime[OvertimeRate] != test.wantOvertime {
+				t.Errorf("overtime %v, want %v", row.Overtime[OvertimeRate], test.wantOvertime)
+			}
+			if row.Overtime[RestDayOvertimeRate] != test.wantRestDay {
+				t.Errorf("rest day overtime %v, want %v", row.Overtime[RestDayOvertimeRate], test.wantRestDay)
+			}
+			if row.Leave[models.LeaveAnnual] != test.wantLeave {
+				t.Errorf("annual leave %v, want %v", row.Leave[models.LeaveAnnual], test.wantLeave)
+			}
+			if row.LateMinutes != test.wantLateMinutes {
+				t.Errorf("late minutes %d, want %d", row.LateMinutes, test.wantLateMinutes)
+			}
+		})
+	}
+}
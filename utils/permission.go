@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"attendance/apperror"
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// EmployeePermissions returns the union of the permissions of every role
+// assigned to the employee.
+func EmployeePermissions(db *gorm.DB, employeeID uint) (map[string]bool, error) {
+	var names []string
+	err := db.Table("permissions").
+		Select("DISTINCT permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN employee_roles ON employee_roles.role_id = role_permissions.role_id").
+		Where("employee_roles.employee_id = ?", employeeID).
+		Pluck("permissions.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	granted := make(map[string]bool, len(names))
+	for _, name := range names {
+		granted[name] = true
+	}
+	return granted, nil
+}
+
+// RoleNames returns the names of the roles assigned to the employee.
+func RoleNames(db *gorm.DB, employeeID uint) ([]string, error) {
+	var names []string
+	err := db.Table("roles").
+		Joins("JOIN employee_roles ON employee_roles.role_id = roles.id").
+		Where("employee_roles.employee_id = ?", employeeID).
+		Order("roles.name").
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+// AssignRoles replaces the roles of an employee with the named roles. It
+// returns gorm.ErrRecordNotFound when one of the names is not a role.
+func AssignRoles(db *gorm.DB, employee *models.Employee, names []string) error {
+	unique := map[string]bool{}
+	for _, name := range names {
+		unique[name] = true
+	}
+
+	var roles []models.Role
+	if len(names) > 0 {
+		if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+			return err
+		}
+	}
+	if len(roles) != len(unique) {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Model(employee).Association("Roles").Replace(roles)
+}
+
+// RequirePermission only lets the request through when the caller, as
+// authenticated by AuthMiddleware, holds every listed permission.
+func RequirePermission(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := CurrentPrincipal(c)
+			if principal == nil {
+				return apperror.ErrUnauthorized
+			}
+
+			for _, permission := range permissions {
+				if !principal.Permissions[permission] {
+					return apperror.ErrForbidden
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// HasPermission reports whether the authenticated caller holds the
+// permission.
+func HasPermission(c echo.Context, permission string) bool {
+	principal := CurrentPrincipal(c)
+	return principal != nil && principal.Permissions[permission]
+}
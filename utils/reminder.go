@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+// StartReminderScheduler checks every minute, until ctx is cancelled, who
+// needs a clock-in or clock-out reminder. REMINDER_CLOCK_IN_MINUTES
+// (default 15) sets how long before the scheduled start the clock-in
+// reminder goes out, REMINDER_CLOCK_OUT_MINUTES (default 15) how long after
+// the scheduled end an open session triggers the clock-out reminder.
+func StartReminderScheduler(ctx context.Context, db *gorm.DB) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if err := SendDueReminders(db, time.Now()); err != nil {
+			log.Println("Error sending reminders:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDueReminders queues the reminders due at now. Employees who opted out,
+// inactive employees and employees on a quiet day (no work scheduled, a
+// holiday or approved leave) are skipped. Schedules, leave, sessions and
+// the reminders already sent are loaded for everyone at once, as this runs
+// every minute; a reminder that cannot be queued is logged and the others
+// still go out.
+func SendDueReminders(db *gorm.DB, now time.Time) error {
+	lead := time.Duration(envInt("REMINDER_CLOCK_IN_MINUTES", 15)) * time.Minute
+	grace := time.Duration(envInt("REMINDER_CLOCK_OUT_MINUTES", 15)) * time.Minute
+	day := StartOfDay(now)
+	date := day.Format(DateLayout)
+
+	holidays, err := HolidaySet(db, day, day)
+	if err != nil || holidays[date] {
+		return err
+	}
+
+	var employees []models.Employee
+	if err := WorkingEmployees(db, now).Where("reminders_opt_out = ?", false).Find(&employees).Error; err != nil {
+		return err
+	}
+	if len(employees) == 0 {
+		return nil
+	}
+	ids := make([]int, len(employees))
+	for i, employee := range employees {
+		ids[i] = int(employee.ID)
+	}
+
+	var schedules []models.WorkSchedule
+	if err := db.Where("employee_id IN ?", ids).Find(&schedules).Error; err != nil {
+		return err
+	}
+	scheduleOf := make(map[int]models.WorkSchedule, len(schedules))
+	for _, schedule := range schedules {
+		scheduleOf[schedule.EmployeeID] = schedule
+	}
+
+	var leaves []models.Leave
+	if err := db.Where("employee_id IN ? AND status = ? AND start_date <= ? AND end_date >= ?", ids, models.LeaveApproved, day, day).
+		Find(&leaves).Error; err != nil {
+		return err
+	}
+	leaveOf := make(map[int]string, len(leaves))
+	for _, leave := range leaves {
+		leaveOf[leave.EmployeeID] = leave.Type
+	}
+
+	open, err := AttendanceSessions(SessionQuery(db).Where("clock_ins.employee_id IN ? AND clock_outs.id IS NULL", ids))
+	if err != nil {
+		return err
+	}
+	isOpen := make(map[int]bool, len(open))
+	for _, session := range open {
+		isOpen[session.EmployeeID] = true
+	}
+
+	var clockedInIDs []int
+	if err := db.Model(&models.ClockIn{}).Where("employee_id IN ? AND clock_in_time >= ?", ids, day).
+		Distinct().Pluck("employee_id", &clockedInIDs).Error; err != nil {
+		return err
+	}
+	clockedIn := make(map[int]bool, len(clockedInIDs))
+	for _, id := range clockedInIDs {
+		clockedIn[id] = true
+	}
+
+	var logs []models.ReminderLog
+	if err := db.Where("date = ?", date).Find(&logs).Error; err != nil {
+		return err
+	}
+	sent := make(map[string]bool, len(logs))
+	for _, entry := range logs {
+		sent[reminderKey(entry.EmployeeID, entry.Kind)] = true
+	}
+
+	for _, employee := range employees {
+		employeeID := int(employee.ID)
+		schedule, ok := scheduleOf[employeeID]
+		if !ok {
+			schedule = DefaultSchedule
+		}
+		today := daySchedule(schedule, day, false, leaveOf[employeeID])
+		if today.StartTime == "" {
+			continue
+		}
+		start, end := ShiftBounds(models.WorkSchedule{StartTime: today.StartTime, EndTime: today.EndTime}, now)
+
+		kind := dueReminder(now, start, end, lead, grace, isOpen[employeeID], clockedIn[employeeID])
+		if kind == "" || sent[reminderKey(employee.ID, kind)] {
+			continue
+		}
+		if kind == models.ReminderClockIn {
+			sendReminder(db, employee, kind, date, models.EventClockInReminder, start)
+		} else {
+			sendReminder(db, employee, kind, date, models.EventClockOutReminder, end)
+		}
+	}
+	return nil
+}
+
+// dueReminder returns the kind of reminder due at now for a shift from start
+// to end, or "" when none is. The clock-in reminder goes out from lead
+// before the start until the start, unless the employee already clocked in
+// today; the clock-out reminder from grace after the end while a session is
+// still open.
+func dueReminder(now, start, end time.Time, lead, grace time.Duration, open, clockedInToday bool) string {
+	if !now.Before(start.Add(-lead)) && now.Before(start) && !open && !clockedInToday {
+		return models.ReminderClockIn
+	}
+	if !now.Before(end.Add(grace)) && open {
+		return models.ReminderClockOut
+	}
+	return ""
+}
+
+func reminderKey(employeeID uint, kind string) string {
+	return strconv.FormatUint(uint64(employeeID), 10) + "/" + kind
+}
+
+// sendReminder queues a reminder unless one of the same kind already went
+// out today. The unique index on ReminderLog makes the check race free.
+func sendReminder(db *gorm.DB, employee models.Employee, kind, date, event string, at time.Time) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.ReminderLog{EmployeeID: employee.ID, Kind: kind, Date: date}).Error; err != nil {
+			return err
+		}
+		return Notify(tx, event, employee.Email, map[string]interface{}{
+			"Fullname": employee.Fullname,
+			"Time":     at.Format("15:04"),
+		})
+	})
+	if err != nil && !IsDuplicateKey(err) {
+		log.Println("Error queueing reminder:", err)
+	}
+}
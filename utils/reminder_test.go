@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"attendance/models"
+)
+
+func TestDueReminder(t *testing.T) {
+	start := time.Date(2023, 5, 2, 9, 0, 0, 0, time.Local)
+	end := time.Date(2023, 5, 2, 17, 0, 0, 0, time.Local)
+	lead, grace := 15*time.Minute, 15*time.Minute
+
+	tests := []struct {
+		name           string
+		now            time.Time
+		open           bool
+		clockedInToday bool
+		want           string
+	}{
+		{"too early", start.Add(-16 * time.Minute), false, false, ""},
+		{"lead starts", start.Add(-15 * time.Minute), false, false, models.ReminderClockIn},
+		{"just before start", start.Add(-time.Minute), false, false, models.ReminderClockIn},
+		{"at start", start, false, false, ""},
+		{"already clocked in", start.Add(-5 * time.Minute), true, true, ""},
+		{"clocked in and out early", start.Add(-5 * time.Minute), false, true, ""},
+		{"before grace ends", end.Add(14 * time.Minute), true, true, ""},
+		{"grace over", end.Add(15 * time.Minute), true, true, models.ReminderClockOut},
+		{"late evening", end.Add(4 * time.Hour), true, true, models.ReminderClockOut},
+		{"clocked out", end.Add(time.Hour), false, true, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := dueReminder(test.now, start, end, lead, grace, test.open, test.clockedInToday)
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDaySchedule(t *testing.T) {
+	schedule := models.WorkSchedule{StartTime: "08:00", EndTime: "16:00", WorkDays: "1,2,3,4,5"}
+	tuesday := time.Date(2023, 5, 2, 12, 0, 0, 0, time.Local)
+	sunday := time.Date(2023, 5, 7, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		day       time.Time
+		holiday   bool
+		leave     string
+		wantStart string
+	}{
+		{"work day", tuesday, false, "", "08:00"},
+		{"weekend", sunday, false, "", ""},
+		{"holiday", tuesday, true, "", ""},
+		{"leave", tuesday, false, models.LeaveTypes[0], ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := daySchedule(schedule, test.day, test.holiday, test.leave)
+			if got.StartTime != test.wantStart {
+				t.Errorf("start %q, want %q", got.StartTime, test.wantStart)
+			}
+			if got.Date != test.day.Format(DateLayout) {
+				t.Errorf("date %q, want %q", got.Date, test.day.Format(DateLayout))
+			}
+		})
+	}
+}
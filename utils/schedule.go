@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+const DateLayout = "2006-01-02"
+
+// DefaultSchedule is used for employees that have no WorkSchedule row.
+var DefaultSchedule = models.WorkSchedule{StartTime: "09:00", EndTime: "17:00", WorkDays: "1,2,3,4,5"}
+
+// ParseDate parses a YYYY-MM-DD string in the server's local time zone.
+func ParseDate(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, errors.New("empty date")
+	}
+	return time.ParseInLocation(DateLayout, str, time.Local)
+}
+
+// StartOfDay truncates t to local midnight.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// ScheduleFor returns the employee's schedule or the default one.
+func ScheduleFor(db *gorm.DB, employeeID int) models.WorkSchedule {
+	var schedule models.WorkSchedule
+	if err := db.Where("employee_id = ?", employeeID).First(&schedule).Error; err != nil {
+		schedule = DefaultSchedule
+		schedule.EmployeeID = employeeID
+	}
+	return schedule
+}
+
+// ValidateSchedule checks the clock times and weekday list of a schedule.
+func ValidateSchedule(schedule models.WorkSchedule) error {
+	start, err := time.Parse("15:04", schedule.StartTime)
+	if err != nil {
+		return errors.New("start_time must be HH:MM")
+	}
+	end, err := time.Parse("15:04", schedule.EndTime)
+	if err != nil {
+		return errors.New("end_time must be HH:MM")
+	}
+	if !end.After(start) {
+		return errors.New("end_time must be after start_time")
+	}
+	for _, day := range strings.Split(schedule.WorkDays, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(day))
+		if err != nil || n < 0 || n > 6 {
+			return errors.New("work_days must be weekday numbers between 0 and 6")
+		}
+	}
+	return nil
+}
+
+// IsWorkDay reports whether the schedule expects work on the given day.
+func IsWorkDay(schedule models.WorkSchedule, day time.Time) bool {
+	weekday := strconv.Itoa(int(day.Weekday()))
+	for _, d := range strings.Split(schedule.WorkDays, ",") {
+		if strings.TrimSpace(d) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// ShiftBounds returns the scheduled start and end of the shift on day.
+func ShiftBounds(schedule models.WorkSchedule, day time.Time) (time.Time, time.Time) {
+	start, _ := time.Parse("15:04", schedule.StartTime)
+	end, _ := time.Parse("15:04", schedule.EndTime)
+	midnight := StartOfDay(day)
+	return midnight.Add(time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute),
+		midnight.Add(time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute)
+}
+
+// HolidaySet returns the holidays between from and to keyed by date.
+func HolidaySet(db *gorm.DB, from, to time.Time) (map[string]bool, error) {
+	var holidays []models.Holiday
+	if err := db.Where("date BETWEEN ? AND ?", from, to).Find(&holidays).Error; err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(holidays))
+	for _, h := range holidays {
+		set[h.Date.Format(DateLayout)] = true
+	}
+	return set, nil
+}
+
+// TodaySchedule describes the employee's schedule for the given day,
+// taking holidays and approved leave into account.
+func TodaySchedule(db *gorm.DB, employeeID int, day time.Time) (models.DaySchedule, error) {
+	day = StartOfDay(day)
+	schedule := ScheduleFor(db, employeeID)
+
+	holidays, err := HolidaySet(db, day, day)
+	if err != nil {
+		return models.DaySchedule{Date: day.Format(DateLayout), WorkDay: IsWorkDay(schedule, day)}, err
+	}
+
+	var leave models.Leave
+	err = db.Where("employee_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", employeeID, models.LeaveApproved, day, day).
+		First(&leave).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DaySchedule{Date: day.Format(DateLayout), WorkDay: IsWorkDay(schedule, day)}, err
+	}
+	return daySchedule(schedule, day, holidays[day.Format(DateLayout)], leave.Type), nil
+}
+
+// daySchedule describes a schedule on day; the shift times are only set
+// when work is expected.
+func daySchedule(schedule models.WorkSchedule, day time.Time, holiday bool, leave string) models.DaySchedule {
+	result := models.DaySchedule{
+		Date:    StartOfDay(day).Format(DateLayout),
+		WorkDay: IsWorkDay(schedule, day),
+		Holiday: holiday,
+		Leave:   leave,
+	}
+	if result.WorkDay && !result.Holiday && result.Leave == "" {
+		result.StartTime = schedule.StartTime
+		result.EndTime = schedule.EndTime
+	}
+	return result
+}
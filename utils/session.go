@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRefreshToken is returned for unknown, consumed, expired and
+// revoked refresh tokens alike.
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// RefreshTokenTTL is how long a refresh token stays valid, configured in
+// days through REFRESH_TOKEN_DAYS (default 30). Each refresh starts a new
+// period.
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(envInt("REFRESH_TOKEN_DAYS", 30)) * 24 * time.Hour
+}
+
+// StartSession opens a session for the employee and returns its first
+// access and refresh tokens. Inactive employees get ErrEmployeeInactive.
+func StartSession(db *gorm.DB, employee models.Employee, userAgent, ip string) (models.TokenResponse, error) {
+	if !employee.CanWork(time.Now()) {
+		return models.TokenResponse{}, ErrEmployeeInactive
+	}
+	var response models.TokenResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		session := models.AuthSession{EmployeeID: employee.ID, UserAgent: userAgent, IP: ip, LastUsedAt: time.Now()}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		response, err = issueTokens(tx, employee, session.ID)
+		return err
+	})
+	return response, err
+}
+
+// RefreshSession consumes a refresh token and returns a new token pair for
+// the same session. A token that was already consumed is treated as stolen
+// and revokes the session.
+func RefreshSession(db *gorm.DB, token string) (models.TokenResponse, error) {
+	var refresh models.RefreshToken
+	if err := db.Where("token_hash = ?", HashToken(token)).First(&refresh).Error; err != nil {
+		return models.TokenResponse{}, ErrInvalidRefreshToken
+	}
+	if refresh.UsedAt != nil {
+		if err := RevokeSession(db, refresh.SessionID); err != nil {
+			return models.TokenResponse{}, err
+		}
+		return models.TokenResponse{}, ErrInvalidRefreshToken
+	}
+	if refresh.ExpiresAt.Before(time.Now()) {
+		return models.TokenResponse{}, ErrInvalidRefreshToken
+	}
+
+	var response models.TokenResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// the used_at condition guards against two concurrent refreshes
+		result := tx.Model(&refresh).Where("used_at IS NULL").Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidRefreshToken
+		}
+
+		var session models.AuthSession
+		if err := tx.Where("revoked_at IS NULL").First(&session, refresh.SessionID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		var employee models.Employee
+		if err := tx.First(&employee, session.EmployeeID).Error; err != nil || !employee.CanWork(now) {
+			return ErrInvalidRefreshToken
+		}
+		if err := tx.Model(&session).Update("last_used_at", now).Error; err != nil {
+			return err
+		}
+
+		var err error
+		response, err = issueTokens(tx, employee, session.ID)
+		return err
+	})
+	return response, err
+}
+
+func issueTokens(db *gorm.DB, employee models.Employee, sessionID uint) (models.TokenResponse, error) {
+	refreshToken, err := RandomToken(32)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	if err := db.Create(&models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+	}).Error; err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	token, expiresAt, err := GenerateToken(int(employee.ID), employee.Role, sessionID)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	roles, err := RoleNames(db, employee.ID)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	return models.TokenResponse{
+		Token:        token,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+		Username:     employee.Username,
+		Email:        employee.Email,
+		Role:         employee.Role,
+		Roles:        roles,
+	}, nil
+}
+
+// RevokeSession logs out a single session.
+func RevokeSession(db *gorm.DB, sessionID uint) error {
+	return db.Model(&models.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeSessions logs the employee out of every session.
+func RevokeSessions(db *gorm.DB, employeeID uint) error {
+	return db.Model(&models.AuthSession{}).
+		Where("employee_id = ? AND revoked_at IS NULL", employeeID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TokenRevoked reports whether an access token can no longer be used: its
+// session was revoked, it predates the employee's last password change, or
+// the employee no longer exists or is no longer active. Tokens without a
+// session were issued before sessions existed and are always revoked.
+func TokenRevoked(db *gorm.DB, employeeID int, sessionID uint, issuedAt time.Time) (bool, error) {
+	if sessionID == 0 {
+		return true, nil
+	}
+
+	var session models.AuthSession
+	if err := db.Select("id", "revoked_at").
+		Where("id = ? AND employee_id = ?", sessionID, employeeID).
+		First(&session).Error; err != nil {
+		return true, err
+	}
+	if session.RevokedAt != nil {
+		return true, nil
+	}
+
+	var employee models.Employee
+	if err := db.Select("id", "password_changed_at", "status", "termination_date").First(&employee, employeeID).Error; err != nil {
+		return true, err
+	}
+	if !employee.CanWork(time.Now()) {
+		return true, nil
+	}
+	return employee.PasswordChangedAt != nil && issuedAt.Unix() < employee.PasswordChangedAt.Unix(), nil
+}
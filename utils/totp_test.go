@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestVerifyTOTPVectors(t *testing.T) {
+	// the last six digits of the RFC 6238 appendix B SHA-1 values
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		t.Run(test.code, func(t *testing.T) {
+			step, ok := VerifyTOTP(rfc6238Secret, test.code, time.Unix(test.unix, 0))
+			if !ok {
+				t.Fatalf("code %s rejected at %d", test.code, test.unix)
+			}
+			if step != test.unix/totpPeriod {
+				t.Errorf("matched step %d, want %d", step, test.unix/totpPeriod)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	// 1111111111 is step 37037037; the code is valid for that step only
+	issued := time.Unix(1111111111, 0)
+	step := issued.Unix() / totpPeriod
+
+	tests := []struct {
+		name string
+		at   time.Time
+		ok   bool
+		step int64
+	}{
+		{"same step", issued, true, step},
+		{"one step later", issued.Add(totpPeriod * time.Second), true, step},
+		{"one step earlier", issued.Add(-totpPeriod * time.Second), true, step},
+		{"two steps later", issued.Add(2 * totpPeriod * time.Second), false, 0},
+		{"two steps earlier", issued.Add(-2 * totpPeriod * time.Second), false, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := VerifyTOTP(rfc6238Secret, "050471", test.at)
+			if ok != test.ok || got != test.step {
+				t.Errorf("got (%d, %v), want (%d, %v)", got, ok, test.step, test.ok)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", rfc6238Secret, "287083"},
+		{"short code", rfc6238Secret, "28708"},
+		{"long code", rfc6238Secret, "2870820"},
+		{"empty code", rfc6238Secret, ""},
+		{"invalid secret", "not base32!", "287082"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := VerifyTOTP(test.secret, test.code, at); ok {
+				t.Error("accepted")
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+	if _, ok := VerifyTOTP(secret, code, time.Now()); !ok {
+		t.Error("a code of a generated secret is rejected")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(TOTPProvisioningURI("JBSWY3DPEHPK3PXP", "jhon@gmail.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || !strings.HasSuffix(uri.Path, ":jhon@gmail.com") {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	if query := uri.Query(); query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("unexpected parameters %s", uri.RawQuery)
+	}
+}
@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidChallenge is returned for unknown, used and expired login
+	// challenges, and for challenges with too many failed codes.
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+	// ErrInvalidTwoFactorCode is returned for wrong, reused and malformed
+	// codes alike.
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	// ErrTwoFactorEnabled is returned when enrolling while 2FA is on.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnrolled is returned when there is nothing to confirm.
+	ErrTwoFactorNotEnrolled = errors.New("two-factor enrollment has not been started")
+)
+
+const (
+	loginChallengeTTL       = 5 * time.Minute
+	loginChallengeAttempts  = 5
+	recoveryCodeCount       = 10
+	recoveryCodeRandomBytes = 5
+)
+
+// TwoFactorRequired reports whether one of the employee's roles requires
+// two-factor authentication.
+func TwoFactorRequired(db *gorm.DB, employeeID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.Role{}).
+		Joins("JOIN employee_roles ON employee_roles.role_id = roles.id").
+		Where("employee_roles.employee_id = ? AND roles.require_two_factor = ?", employeeID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// EnabledTwoFactor returns the employee's 2FA record if it is enabled, and
+// nil otherwise.
+func EnabledTwoFactor(db *gorm.DB, employeeID uint) (*models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	err := db.Where("employee_id = ? AND enabled_at IS NOT NULL", employeeID).First(&twoFactor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &twoFactor, nil
+}
+
+// BeginTwoFactorEnrollment stores a new secret for the employee, replacing
+// an unconfirmed one. 2FA is enabled by ConfirmTwoFactorEnrollment.
+func BeginTwoFactorEnrollment(db *gorm.DB, employee models.Employee) (models.TwoFactorEnrollResponse, error) {
+	enabled, err := EnabledTwoFactor(db, employee.ID)
+	if err != nil {
+		return models.TwoFactorEnrollResponse{}, err
+	}
+	if enabled != nil {
+		return models.TwoFactorEnrollResponse{}, ErrTwoFactorEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return models.TwoFactorEnrollResponse{}, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("employee_id = ?", employee.ID).Delete(&models.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TwoFactor{EmployeeID: employee.ID, Secret: secret}).Error
+	})
+	if err != nil {
+		return models.TwoFactorEnrollResponse{}, err
+	}
+
+	return models.TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: TOTPProvisioningURI(secret, employee.Username),
+	}, nil
+}
+
+// ConfirmTwoFactorEnrollment enables 2FA once the employee proves their
+// authenticator works, and returns their recovery codes.
+func ConfirmTwoFactorEnrollment(db *gorm.DB, employeeID uint, code string) ([]string, error) {
+	var twoFactor models.TwoFactor
+	if err := db.Where("employee_id = ?", employeeID).First(&twoFactor).Error; err != nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if twoFactor.EnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	step, ok := VerifyTOTP(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&twoFactor).Updates(map[string]interface{}{"enabled_at": time.Now(), "last_used_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = RegenerateRecoveryCodes(tx, employeeID)
+		return err
+	})
+	return codes, err
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery
+// code of an employee with 2FA enabled.
+func VerifySecondFactor(db *gorm.DB, employeeID uint, code string) error {
+	twoFactor, err := EnabledTwoFactor(db, employeeID)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil {
+		return ErrInvalidTwoFactorCode
+	}
+
+	if step, ok := VerifyTOTP(twoFactor.Secret, code, time.Now()); ok {
+		// the step condition makes a code single-use, even under concurrency
+		result := db.Model(twoFactor).Where("last_used_step < ?", step).Update("last_used_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("employee_id = ? AND code_hash = ? AND used_at IS NULL", employeeID, HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the employee's recovery codes and
+// returns the new ones in plain text.
+func RegenerateRecoveryCodes(db *gorm.DB, employeeID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, recoveryCodeRandomBytes)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(buf)
+		codes[i] = code[:5] + "-" + code[5:]
+		rows[i] = models.RecoveryCode{EmployeeID: employeeID, CodeHash: HashToken(code)}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("employee_id = ?", employeeID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	return codes, err
+}
+
+// DisableTwoFactor removes the employee's secret and recovery codes.
+func DisableTwoFactor(db *gorm.DB, employeeID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("employee_id = ?", employeeID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("employee_id = ?", employeeID).Delete(&models.TwoFactor{}).Error
+	})
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// CreateLoginChallenge records that the employee passed the password step
+// and returns the token for the second step.
+func CreateLoginChallenge(db *gorm.DB, employeeID uint) (string, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return token, db.Create(&models.LoginChallenge{
+		EmployeeID: employeeID,
+		TokenHash:  HashToken(token),
+		ExpiresAt:  time.Now().Add(loginChallengeTTL),
+	}).Error
+}
+
+// FindLoginChallenge returns the open login challenge of token and the
+// employee it was issued to, so the caller can apply the login throttle
+// before a code is checked.
+func FindLoginChallenge(db *gorm.DB, token string) (models.LoginChallenge, models.Employee, error) {
+	var challenge models.LoginChallenge
+	if err := db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?",
+		HashToken(token), time.Now(), loginChallengeAttempts).First(&challenge).Error; err != nil {
+		return challenge, models.Employee{}, ErrInvalidChallenge
+	}
+	var employee models.Employee
+	if err := db.First(&employee, challenge.EmployeeID).Error; err != nil {
+		return challenge, employee, ErrInvalidChallenge
+	}
+	return challenge, employee, nil
+}
+
+// CompleteLoginChallenge checks the code for a login challenge. When the
+// challenge was issued for enrollment the code confirms it, and the new
+// recovery codes are returned. Each challenge allows a few wrong codes;
+// callers also count them against the login throttle, as anyone who knows
+// the password can start a new challenge.
+func CompleteLoginChallenge(db *gorm.DB, challenge models.LoginChallenge, code string) ([]string, error) {
+	enabled, err := EnabledTwoFactor(db, challenge.EmployeeID)
+	if err != nil {
+		return nil, err
+	}
+	var recoveryCodes []string
+	if enabled != nil {
+		err = VerifySecondFactor(db, challenge.EmployeeID, code)
+	} else {
+		recoveryCodes, err = ConfirmTwoFactorEnrollment(db, challenge.EmployeeID, code)
+	}
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		db.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1"))
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := db.Model(&challenge).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidChallenge
+	}
+	return recoveryCodes, nil
+}
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"attendance/apperror"
+	"attendance/models"
+)
+
+// Validator checks the validate struct tags of request bodies, e.g.
+// `validate:"required,email,max=100"`. It is installed as the Echo
+// validator, so handlers call c.Validate after c.Bind and return its error.
+//
+// Apart from required, rules skip empty values, and nil pointers count as
+// empty. The rules are:
+//
+//	required   the value is not empty (strings are trimmed)
+//	min=N      strings have at least N characters, slices N items, numbers are at least N
+//	max=N      the same, at most N
+//	email      a plain address such as jhon@gmail.com
+//	password   see PasswordMinLength; a letter and a digit, at most 72 bytes
+//	phone      digits, spaces, dashes and brackets with an optional leading +, 7 to 15 digits
+//	username   letters, digits, dots, dashes and underscores
+//	date       a YYYY-MM-DD date
+//	url        an absolute http or https URL
+//	oneof=a b  one of the space separated values
+type Validator struct{}
+
+// Validate implements echo.Validator. Invalid requests get an
+// apperror.ErrValidationFailed whose details list the invalid fields.
+func (v *Validator) Validate(i interface{}) error {
+	var fields []models.FieldError
+	validateStruct(reflect.ValueOf(i), &fields)
+	if len(fields) > 0 {
+		return apperror.ErrValidationFailed.WithDetails(fields)
+	}
+	return nil
+}
+
+// PasswordMinLength is the shortest accepted password, configured through
+// PASSWORD_MIN_LENGTH (default 8).
+func PasswordMinLength() int {
+	return envInt("PASSWORD_MIN_LENGTH", 8)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func validateStruct(value reflect.Value, fields *[]models.FieldError) {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			validateStruct(value.Field(i), fields)
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		fieldValue := value.Field(i)
+		for fieldValue.Kind() == reflect.Pointer && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			rule, param, _ := strings.Cut(rule, "=")
+			message := checkRule(rule, param, fieldValue)
+			if message != "" {
+				*fields = append(*fields, models.FieldError{Field: name, Rule: rule, Message: message})
+				// one message per field is enough to fix the input
+				break
+			}
+		}
+	}
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return value.IsNil()
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+var (
+	phonePattern    = regexp.MustCompile(`^\+?[0-9 ()-]+$`)
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+func checkRule(rule, param string, value reflect.Value) string {
+	if rule == "required" {
+		if isEmpty(value) {
+			return "is required"
+		}
+		return ""
+	}
+	if isEmpty(value) {
+		return ""
+	}
+
+	switch rule {
+	case "min", "max":
+		return checkLength(rule, param, value)
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return "must be a valid email address"
+		}
+	case "password":
+		return checkPassword(value.String())
+	case "phone":
+		digits := 0
+		for _, r := range value.String() {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		if !phonePattern.MatchString(value.String()) || digits < 7 || digits > 15 {
+			return "must be a phone number of 7 to 15 digits"
+		}
+	case "username":
+		if !usernamePattern.MatchString(value.String()) {
+			return "may only contain letters, digits, dots, dashes and underscores"
+		}
+	case "date":
+		if _, err := time.Parse(DateLayout, value.String()); err != nil {
+			return "must be a date formatted as YYYY-MM-DD"
+		}
+	case "url":
+		parsed, err := url.ParseRequestURI(value.String())
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "must be an http or https URL"
+		}
+	case "oneof":
+		for _, allowed := range strings.Fields(param) {
+			if value.String() == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	default:
+		panic(fmt.Sprintf("unknown validation rule %q", rule))
+	}
+	return ""
+}
+
+func checkLength(rule, param string, value reflect.Value) string {
+	limit, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s parameter %q", rule, param))
+	}
+
+	var size int
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		size, unit = utf8.RuneCountInString(value.String()), " characters"
+	case reflect.Slice, reflect.Map:
+		size, unit = value.Len(), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = int(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = int(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = int(value.Float())
+	}
+
+	if rule == "min" && size < limit {
+		if unit == "" {
+			return fmt.Sprintf("must be at least %d", limit)
+		}
+		return fmt.Sprintf("must have at least %d%s", limit, unit)
+	}
+	if rule == "max" && size > limit {
+		if unit == "" {
+			return fmt.Sprintf("must be at most %d", limit)
+		}
+		return fmt.Sprintf("must have at most %d%s", limit, unit)
+	}
+	return ""
+}
+
+func checkPassword(password string) string {
+	if utf8.RuneCountInString(password) < PasswordMinLength() {
+		return fmt.Sprintf("must have at least %d characters", PasswordMinLength())
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		return "must be at most 72 bytes long"
+	}
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	if !letter || !digit {
+		return "must contain a letter and a digit"
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"attendance/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// VisibleEmployeeIDs returns the employees the caller may see: themselves
+// and their reporting subtree. A nil slice means the caller holds
+// visibility.all and may see everyone.
+func VisibleEmployeeIDs(c echo.Context, db *gorm.DB) ([]uint, error) {
+	if HasPermission(c, models.PermVisibilityAll) {
+		return nil, nil
+	}
+	callerID := CallerID(c)
+	ids, err := ReportingSubtree(db, uint(callerID))
+	if err != nil {
+		return nil, err
+	}
+	return append(ids, uint(callerID)), nil
+}
+
+// CanSeeEmployee reports whether the employee is visible to the caller.
+func CanSeeEmployee(c echo.Context, db *gorm.DB, employeeID uint) (bool, error) {
+	ids, err := VisibleEmployeeIDs(c, db)
+	if err != nil || ids == nil {
+		return err == nil, err
+	}
+	for _, id := range ids {
+		if id == employeeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CanSeePersonalDetails reports whether the caller may see the phone number
+// and address of the employee: their own, or anyone's with
+// employees.personal.
+func CanSeePersonalDetails(c echo.Context, employeeID uint) bool {
+	principal := CurrentPrincipal(c)
+	if principal == nil {
+		return false
+	}
+	return (!principal.IsAPIKey() && uint(principal.EmployeeID) == employeeID) || principal.Permissions[models.PermEmployeesPersonal]
+}
+
+// VisibleTo restricts a query to rows whose column holds an employee the
+// caller may see.
+func VisibleTo(c echo.Context, db *gorm.DB, column string) (*gorm.DB, error) {
+	ids, err := VisibleEmployeeIDs(c, db)
+	if err != nil || ids == nil {
+		return db, err
+	}
+	return db.Where(column+" IN ?", ids), nil
+}
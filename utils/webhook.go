@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"attendance/models"
+
+	"gorm.io/gorm"
+)
+
+// PublishEvent queues a delivery of event to every active webhook that
+// subscribes to it. Errors are logged rather than returned so that a
+// webhook problem never fails the request that triggered the event.
+func PublishEvent(db *gorm.DB, event string, data interface{}) {
+	var webhooks []models.Webhook
+	if err := db.Where("active = ?", true).Find(&webhooks).Error; err != nil {
+		log.Println("Error loading webhooks:", err)
+		return
+	}
+
+	eventID, err := RandomToken(16)
+	if err != nil {
+		log.Println("Error generating event ID:", err)
+		return
+	}
+	payload, err := json.Marshal(models.WebhookPayload{ID: eventID, Event: event, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		log.Println("Error encoding webhook payload:", err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !WebhookSubscribes(webhook, event) {
+			continue
+		}
+		delivery := models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := db.Create(&delivery).Error; err != nil {
+			log.Println("Error queueing webhook delivery:", err)
+		}
+	}
+}
+
+// WebhookSubscribes reports whether the webhook wants the event.
+func WebhookSubscribes(webhook models.Webhook, event string) bool {
+	for _, subscribed := range strings.Split(webhook.Events, ",") {
+		if subscribed = strings.TrimSpace(subscribed); subscribed == "*" || subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidWebhookEvent reports whether event can be subscribed to.
+func ValidWebhookEvent(event string) bool {
+	if event == "*" {
+		return true
+	}
+	for _, known := range models.WebhookEvents {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhook returns the signature header value for a payload sent at
+// timestamp: "sha256=" followed by the hex HMAC-SHA256 of
+// "<timestamp>.<payload>" keyed with the webhook secret.
+func SignWebhook(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a received signature in constant time.
+func VerifyWebhookSignature(secret string, timestamp int64, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, timestamp, payload)), []byte(signature))
+}
+
+// ReplayDelivery queues a fresh copy of an earlier delivery.
+func ReplayDelivery(db *gorm.DB, original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	replay := models.WebhookDelivery{
+		WebhookID:     original.WebhookID,
+		EventID:       original.EventID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	return replay, db.Create(&replay).Error
+}
+
+// StartWebhookWorker posts queued deliveries until ctx is cancelled. Failed
+// deliveries are retried with exponential backoff and marked failed after
+// WEBHOOK_MAX_ATTEMPTS (default 8) attempts.
+func StartWebhookWorker(ctx context.Context, db *gorm.DB, client *http.Client) {
+	interval := time.Duration(envInt("WEBHOOK_POLL_SECONDS", 5)) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := DeliverWebhooks(db, client); err != nil {
+			log.Println("Error delivering webhooks:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverWebhooks posts every delivery that is due, claiming rows with a
+// conditional update so several server instances can share the queue.
+func DeliverWebhooks(db *gorm.DB, client *http.Client) error {
+	now := time.Now()
+
+	// release rows left in "sending" by an instance that died mid-delivery
+	db.Model(&models.WebhookDelivery{}).
+		Where("status = ? AND updated_at < ?", models.DeliverySending, now.Add(-5*time.Minute)).
+		Update("status", models.DeliveryPending)
+
+	var due []models.WebhookDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at").Limit(50).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for _, delivery := range due {
+		claimed := db.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ?", delivery.ID, models.DeliveryPending).
+			Update("status", models.DeliverySending)
+		if claimed.Error != nil || claimed.RowsAffected == 0 {
+			continue
+		}
+
+		var webhook models.Webhook
+		if err := db.First(&webhook, delivery.WebhookID).Error; err != nil {
+			db.Model(&delivery).Updates(map[string]interface{}{"status": models.DeliveryFailed, "last_error": "webhook no longer exists"})
+			continue
+		}
+		postDelivery(db, client, webhook, delivery)
+	}
+	return nil
+}
+
+func postDelivery(db *gorm.DB, client *http.Client, webhook models.Webhook, delivery models.WebhookDelivery) {
+	updates := map[string]interface{}{"attempts": delivery.Attempts + 1}
+
+	status, body, err := postWebhook(client, webhook, delivery)
+	updates["response_status"] = status
+	updates["response_body"] = body
+	if err == nil && status >= 200 && status < 300 {
+		updates["status"] = models.DeliveryDelivered
+		updates["delivered_at"] = time.Now()
+		updates["last_error"] = ""
+	} else {
+		if err == nil {
+			err = fmt.Errorf("unexpected response status %d", status)
+		}
+		updates["last_error"] = err.Error()
+		if delivery.Attempts+1 >= envInt("WEBHOOK_MAX_ATTEMPTS", 8) {
+			updates["status"] = models.DeliveryFailed
+		} else {
+			updates["status"] = models.DeliveryPending
+			updates["next_attempt_at"] = time.Now().Add(Backoff(delivery.Attempts + 1))
+		}
+	}
+
+	if err := db.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error; err != nil {
+		log.Println("Error updating webhook delivery:", err)
+	}
+}
+
+func postWebhook(client *http.Client, webhook models.Webhook, delivery models.WebhookDelivery) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	if request.URL.Scheme != "https" && !webhookAllowInsecure() {
+		return 0, "", errors.New("webhook URL must use https")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "AttendanceApp-Webhooks/1.0")
+	request.Header.Set("X-Webhook-Event", delivery.Event)
+	request.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", SignWebhook(webhook.Secret, timestamp, payload))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 2048))
+	return response.StatusCode, string(body), nil
+}
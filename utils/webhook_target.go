@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookTarget is returned for webhook URLs that point into the server's
+// own network.
+var ErrWebhookTarget = errors.New("webhook URL must point to a public address")
+
+// webhookBlockedNets are address ranges that are never public, on top of the
+// loopback, private, link-local, multicast and unspecified ones net.IP
+// reports itself.
+var webhookBlockedNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// webhookAllowInsecure lets webhooks use plain http and private addresses,
+// configured through WEBHOOK_ALLOW_INSECURE. It is meant for trying webhooks
+// against a local receiver only.
+func webhookAllowInsecure() bool {
+	allow, _ := strconv.ParseBool(envOr("WEBHOOK_ALLOW_INSECURE", "false"))
+	return allow
+}
+
+// PublicIP reports whether ip may receive webhook deliveries.
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range webhookBlockedNets {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhookURL checks that a webhook URL uses https and that its host
+// only resolves to public addresses. Deliveries check the address again
+// when connecting, as DNS answers can change.
+func ValidateWebhookURL(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Hostname() == "" {
+		return errors.New("webhook URL must be an absolute URL")
+	}
+	if webhookAllowInsecure() {
+		return nil
+	}
+	if target.Scheme != "https" {
+		return errors.New("webhook URL must use https")
+	}
+	if target.User != nil {
+		return errors.New("webhook URL must not contain credentials")
+	}
+
+	if ip := net.ParseIP(target.Hostname()); ip != nil {
+		if !PublicIP(ip) {
+			return ErrWebhookTarget
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return fmt.Errorf("webhook host %s does not resolve", target.Hostname())
+	}
+	for _, address := range addresses {
+		if !PublicIP(address.IP) {
+			return ErrWebhookTarget
+		}
+	}
+	return nil
+}
+
+// NewWebhookClient returns the HTTP client deliveries are posted with. It
+// refuses to connect to non-public addresses, checked on the address it
+// actually dials after DNS resolution, ignores proxy settings and never
+// follows redirects, so a receiver cannot point the server at its own
+// network.
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			if webhookAllowInsecure() {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return ErrWebhookTarget
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}